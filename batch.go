@@ -0,0 +1,160 @@
+package riakpbc
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of requests a batch keeps in flight
+// unless BatchOptions say otherwise.
+const DefaultBatchConcurrency = 8
+
+// BatchOptions are the optional parameters of FetchMany and StoreMany.
+type BatchOptions struct {
+	Concurrency int         // requests in flight at once, DefaultBatchConcurrency if zero
+	Get         *GetOptions // options of every fetch
+	Put         *PutOptions // options of every store
+}
+
+func (opts *BatchOptions) concurrency() int {
+	if opts == nil || opts.Concurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return opts.Concurrency
+}
+
+func (opts *BatchOptions) get() []*GetOptions {
+	if opts == nil || opts.Get == nil {
+		return nil
+	}
+	return []*GetOptions{opts.Get}
+}
+
+func (opts *BatchOptions) put() []*PutOptions {
+	if opts == nil || opts.Put == nil {
+		return nil
+	}
+	return []*PutOptions{opts.Put}
+}
+
+// FetchResult is the outcome of fetching one key of a batch.
+type FetchResult struct {
+	Key      string
+	Response *RpbGetResp
+	Err      error
+}
+
+// StoreResult is the outcome of storing one key of a batch.
+type StoreResult struct {
+	Key      string
+	Response *RpbPutResp
+	Err      error
+}
+
+// batch calls fn for 0 to n-1, at most concurrency at a time, and returns
+// once every call has.
+func batch(n, concurrency int, fn func(i int)) {
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// sortedKeys returns the keys of a batch of values in order.
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FetchMany fetches keys from bucket concurrently, spreading the requests
+// over the nodes of the pool. Results come back in the order of keys; a key
+// that failed carries its error, ErrObjectNotFound if it doesn't exist.
+func (c *Client) FetchMany(bucket string, keys []string, opts *BatchOptions) []*FetchResult {
+	c, span := c.startOperation("FetchMany")
+	span.SetAttribute(AttrBucket, bucket)
+	defer span.End()
+
+	results := make([]*FetchResult, len(keys))
+
+	batch(len(keys), opts.concurrency(), func(i int) {
+		response, err := c.FetchObject(bucket, keys[i], opts.get()...)
+		results[i] = &FetchResult{Key: keys[i], Response: response, Err: err}
+	})
+
+	return results
+}
+
+// StoreMany stores values in bucket under their keys concurrently. Values
+// are anything StoreStruct accepts: structs are encoded with the client's
+// Coder, anything else stored as StoreObject would. Results come back in
+// the order of the keys.
+func (c *Client) StoreMany(bucket string, values map[string]interface{}, opts *BatchOptions) []*StoreResult {
+	c, span := c.startOperation("StoreMany")
+	span.SetAttribute(AttrBucket, bucket)
+	defer span.End()
+
+	keys := sortedKeys(values)
+	results := make([]*StoreResult, len(keys))
+
+	batch(len(keys), opts.concurrency(), func(i int) {
+		response, err := c.StoreStruct(bucket, keys[i], values[keys[i]], opts.put()...)
+		results[i] = &StoreResult{Key: keys[i], Response: response, Err: err}
+	})
+
+	return results
+}
+
+// Result is the outcome of getting one key of a typed batch.
+type Result[T any] struct {
+	Key   string
+	Value T
+	Meta  *Meta
+	Err   error
+}
+
+// GetMany gets keys concurrently, see Client.FetchMany().
+func (b *Bucket[T]) GetMany(keys []string, opts *BatchOptions) []*Result[T] {
+	b, span := b.startOperation("GetMany")
+	defer span.End()
+
+	results := make([]*Result[T], len(keys))
+
+	batch(len(keys), opts.concurrency(), func(i int) {
+		value, meta, err := b.Get(keys[i], opts.get()...)
+		results[i] = &Result[T]{Key: keys[i], Value: value, Meta: meta, Err: err}
+	})
+
+	return results
+}
+
+// PutMany puts values under their keys concurrently, see Client.StoreMany().
+func (b *Bucket[T]) PutMany(values map[string]T, opts *BatchOptions) []*StoreResult {
+	b, span := b.startOperation("PutMany")
+	defer span.End()
+
+	keys := sortedKeys(values)
+	results := make([]*StoreResult, len(keys))
+
+	batch(len(keys), opts.concurrency(), func(i int) {
+		response, err := b.put(keys[i], values[keys[i]], opts.put())
+		results[i] = &StoreResult{Key: keys[i], Response: response, Err: err}
+	})
+
+	return results
+}
@@ -0,0 +1,79 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchConcurrency(t *testing.T) {
+	var lock sync.Mutex
+	running, peak := 0, 0
+	called := make([]bool, 20)
+
+	batch(len(called), 3, func(i int) {
+		lock.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		lock.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		called[i] = true
+		lock.Unlock()
+	})
+
+	assert.Equal(t, 3, peak)
+	for i := range called {
+		assert.T(t, called[i])
+	}
+}
+
+func TestFetchAndStoreMany(t *testing.T) {
+	server, addr := newMemServer(t)
+	other := newTestServer(t, server.handle)
+
+	riak := NewClient([]string{addr, other})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	stored := riak.StoreMany("bucket", map[string]interface{}{
+		"c":      "c value",
+		"a":      []byte("a value"),
+		"b":      &Data{Data: "b value"},
+		"broken": []int{1},
+	}, &BatchOptions{Concurrency: 2, Put: &PutOptions{W: QuorumOne}})
+
+	assert.Equal(t, 4, len(stored))
+	for i, key := range []string{"a", "b", "broken", "c"} {
+		assert.Equal(t, key, stored[i].Key)
+	}
+	assert.T(t, stored[0].Err == nil)
+	assert.T(t, stored[1].Err == nil)
+	assert.T(t, stored[2].Err != nil)
+	assert.T(t, stored[3].Err == nil)
+
+	fetched := riak.FetchMany("bucket", []string{"c", "missing", "a"}, nil)
+	assert.Equal(t, 3, len(fetched))
+	assert.Equal(t, "c", fetched[0].Key)
+	assert.Equal(t, "c value", string(fetched[0].Response.GetContent()[0].GetValue()))
+	assert.Equal(t, ErrObjectNotFound, fetched[1].Err)
+	assert.Equal(t, "a value", string(fetched[2].Response.GetContent()[0].GetValue()))
+
+	datas := NewBucket[Data](riak, "data")
+	put := datas.PutMany(map[string]Data{"x": {Data: "x"}, "y": {Data: "y"}}, nil)
+	assert.Equal(t, 2, len(put))
+	assert.T(t, put[0].Err == nil && put[1].Err == nil)
+
+	got := datas.GetMany([]string{"y", "x", "z"}, &BatchOptions{Concurrency: 1})
+	assert.Equal(t, "y", got[0].Value.Data)
+	assert.Equal(t, "x", got[1].Value.Data)
+	assert.T(t, got[1].Meta != nil)
+	assert.Equal(t, ErrObjectNotFound, got[2].Err)
+}
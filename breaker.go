@@ -0,0 +1,218 @@
+package riakpbc
+
+import (
+	"time"
+)
+
+// NodeState is the circuit breaker state of a Node.
+type NodeState int
+
+const (
+	NodeHealthy  NodeState = iota // serving requests normally
+	NodeDegraded                  // serving requests, but with a raised error rate
+	NodeOpen                      // circuit tripped, never selected for requests
+	NodeHalfOpen                  // circuit testing the node with probe requests
+)
+
+var nodeStateNames = map[NodeState]string{
+	NodeHealthy:  "healthy",
+	NodeDegraded: "degraded",
+	NodeOpen:     "open",
+	NodeHalfOpen: "half-open",
+}
+
+func (state NodeState) String() string {
+	if name, ok := nodeStateNames[state]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// BreakerConfig holds the thresholds which drive a Node between states.
+//
+// The error rate compared against the thresholds is the node's decaying
+// error rate, see decaying.go.
+type BreakerConfig struct {
+	DegradedThreshold float64       // error rate at which a healthy node becomes degraded
+	OpenThreshold     float64       // error rate at which the circuit opens
+	OpenTimeout       time.Duration // time an open circuit waits before going half-open
+	HalfOpenProbes    int           // successful probes needed to close a half-open circuit
+}
+
+// DefaultBreakerConfig returns the thresholds every new Node starts with.
+func DefaultBreakerConfig() *BreakerConfig {
+	return &BreakerConfig{
+		DegradedThreshold: NODE_DEGRADED_THRESHOLD,
+		OpenThreshold:     NODE_ERROR_THRESHOLD,
+		OpenTimeout:       NODE_OPEN_TIMEOUT,
+		HalfOpenProbes:    NODE_HALF_OPEN_PROBES,
+	}
+}
+
+// StateChangeFunc is called after a Node moves from one state to another.
+type StateChangeFunc func(node *Node, from, to NodeState)
+
+// State returns the current state of the node.
+//
+// Time based transitions happen lazily: an open circuit turns half-open once
+// its timeout has passed, and a degraded node recovers once its error rate
+// has decayed below the degraded threshold.
+func (node *Node) State() NodeState {
+	defer node.fireStateChanges()
+	return node.currentState()
+}
+
+// currentState is State without firing the state change callbacks, for
+// callers holding a lock, see fireStateChanges.
+func (node *Node) currentState() NodeState {
+	return node.setState(nil)
+}
+
+// SetBreakerConfig replaces the thresholds used by the node's state machine.
+func (node *Node) SetBreakerConfig(config *BreakerConfig) {
+	node.statelock.Lock()
+	node.breaker = config
+	node.statelock.Unlock()
+}
+
+// OnStateChange registers a callback fired on every state transition.
+//
+// Callbacks run on a goroutine that noticed the transition, once it has
+// released the node, pipeline and pool locks, so they may call back into the
+// node. They run one at a time and in order, and should return quickly.
+func (node *Node) OnStateChange(fn StateChangeFunc) {
+	node.statelock.Lock()
+	node.stateFuncs = append(node.stateFuncs, fn)
+	node.statelock.Unlock()
+}
+
+// RecordSuccess records a successful request against the node.
+//
+// In the half-open state each success counts as a probe; once enough probes
+// have succeeded the circuit closes.
+func (node *Node) RecordSuccess() {
+	node.recordSuccess()
+	node.fireStateChanges()
+}
+
+// recordSuccess is RecordSuccess without firing the state change callbacks.
+func (node *Node) recordSuccess() {
+	node.setState(func(state NodeState, rate float64, breaker *BreakerConfig) NodeState {
+		switch state {
+		case NodeHalfOpen:
+			node.probes++
+			if node.probes < breaker.HalfOpenProbes {
+				return NodeHalfOpen
+			}
+			if rate >= breaker.DegradedThreshold {
+				return NodeDegraded
+			}
+			return NodeHealthy
+		case NodeDegraded:
+			if rate < breaker.DegradedThreshold {
+				return NodeHealthy
+			}
+		}
+		return state
+	})
+}
+
+// RecordError increments the current error value - see decaying.go - and
+// trips the circuit once the error rate passes the open threshold.
+//
+// Any error while half-open reopens the circuit.
+func (node *Node) RecordError(amount float64) {
+	node.recordError(amount)
+	node.fireStateChanges()
+}
+
+// recordError is RecordError without firing the state change callbacks.
+func (node *Node) recordError(amount float64) {
+	node.errorRate.Add(amount)
+	node.setState(func(state NodeState, rate float64, breaker *BreakerConfig) NodeState {
+		switch {
+		case state == NodeOpen, state == NodeHalfOpen, rate >= breaker.OpenThreshold:
+			return NodeOpen
+		case rate >= breaker.DegradedThreshold:
+			return NodeDegraded
+		}
+		return state
+	})
+}
+
+// stateChange is a transition awaiting its callbacks.
+type stateChange struct {
+	from, to NodeState
+}
+
+// setState applies the lazy time based transitions, then next if given, and
+// queues every transition that happened for fireStateChanges.
+func (node *Node) setState(next func(NodeState, float64, *BreakerConfig) NodeState) NodeState {
+	rate := node.ErrorRate()
+
+	node.statelock.Lock()
+	defer node.statelock.Unlock()
+
+	apply := func(to NodeState) {
+		if to == node.state {
+			return
+		}
+		node.changes = append(node.changes, stateChange{node.state, to})
+		node.state = to
+		node.stateChanged = time.Now()
+		node.probes = 0
+	}
+
+	switch node.state {
+	case NodeOpen:
+		if time.Since(node.stateChanged) >= node.breaker.OpenTimeout {
+			apply(NodeHalfOpen)
+		}
+	case NodeDegraded:
+		if rate < node.breaker.DegradedThreshold {
+			apply(NodeHealthy)
+		}
+	}
+
+	if next != nil {
+		apply(next(node.state, rate, node.breaker))
+	}
+
+	return node.state
+}
+
+// fireStateChanges logs the queued transitions and calls the state change
+// callbacks for them. It must be called without the node, pipeline or pool
+// locks held. Transitions queued while another goroutine fires them, such as
+// by the callbacks themselves, are fired by that goroutine, in order.
+func (node *Node) fireStateChanges() {
+	node.statelock.Lock()
+	if node.firing {
+		node.statelock.Unlock()
+		return
+	}
+	node.firing = true
+
+	for len(node.changes) > 0 {
+		changes := node.changes
+		funcs := node.stateFuncs
+		node.changes = nil
+		node.statelock.Unlock()
+
+		for _, c := range changes {
+			if c.to == NodeOpen {
+				node.log().Warn("node circuit opened", "node", node.addr, "from", c.from.String())
+			} else {
+				node.log().Info("node state changed", "node", node.addr, "from", c.from.String(), "to", c.to.String())
+			}
+			for _, fn := range funcs {
+				fn(node, c.from, c.to)
+			}
+		}
+
+		node.statelock.Lock()
+	}
+
+	node.firing = false
+	node.statelock.Unlock()
+}
@@ -0,0 +1,114 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestBreakerStates(t *testing.T) {
+	node, err := NewNode("127.0.0.1:8087", NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.SetBreakerConfig(&BreakerConfig{
+		DegradedThreshold: 0.1,
+		OpenThreshold:     0.5,
+		OpenTimeout:       10 * time.Millisecond,
+		HalfOpenProbes:    2,
+	})
+
+	var changes []NodeState
+	node.OnStateChange(func(n *Node, from, to NodeState) {
+		assert.T(t, n == node)
+		changes = append(changes, to)
+	})
+
+	assert.T(t, node.State() == NodeHealthy)
+	assert.T(t, node.GetOk())
+
+	node.RecordError(0.2)
+	assert.T(t, node.State() == NodeDegraded)
+
+	node.RecordError(1.0)
+	assert.T(t, node.State() == NodeOpen)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.T(t, node.State() == NodeHalfOpen)
+
+	node.RecordError(0.1)
+	assert.T(t, node.State() == NodeOpen)
+
+	time.Sleep(20 * time.Millisecond)
+	node.RecordSuccess()
+	assert.T(t, node.State() == NodeHalfOpen)
+	node.RecordSuccess()
+	assert.T(t, node.State() == NodeDegraded)
+
+	assert.T(t, len(changes) == 6)
+	assert.T(t, changes[len(changes)-1] == NodeDegraded)
+}
+
+func TestPoolSkipsOpenNodes(t *testing.T) {
+	pool := NewPool([]string{"127.0.0.1:8087", "127.0.0.1:8088"})
+	pool.nodes["127.0.0.1:8087"].RecordError(1.0)
+
+	for i := 0; i < 10; i++ {
+		node, err := pool.SelectNode()
+		assert.T(t, err == nil)
+		assert.T(t, node.Addr() == "127.0.0.1:8088")
+	}
+
+	pool.nodes["127.0.0.1:8088"].RecordError(1.0)
+	_, err := pool.SelectNode()
+	assert.T(t, err == ErrAllNodesDown)
+}
+
+func TestStateChangeCallbacksUseNode(t *testing.T) {
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		if structname == "RpbGetReq" {
+			return "", nil
+		}
+		return "RpbPingResp", nil
+	})
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	node := riak.Pool().Nodes()[0]
+	node.SetBreakerConfig(&BreakerConfig{
+		DegradedThreshold: 0.1,
+		OpenThreshold:     0.5,
+		OpenTimeout:       10 * time.Millisecond,
+		HalfOpenProbes:    1,
+	})
+
+	// Callbacks call back into the node and the pool, which deadlocks if
+	// they run with either locked
+	changes := make(chan NodeState, 16)
+	node.OnStateChange(func(n *Node, from, to NodeState) {
+		n.SetLogger(nopLogger{})
+		n.Ping()
+		riak.SelectNode()
+		changes <- to
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The failed read opens the circuit with the node locked
+		riak.FetchObject("bucket", "key")
+		// Selecting the node turns it half-open with the pool locked
+		time.Sleep(20 * time.Millisecond)
+		riak.SelectNode()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("state change callbacks deadlocked")
+	}
+
+	assert.Equal(t, NodeOpen, <-changes)
+	assert.Equal(t, NodeHalfOpen, <-changes)
+}
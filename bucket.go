@@ -1,10 +1,62 @@
 package riakpbc
 
-// ListBuckets lists all buckets.
-func (c *Client) ListBuckets() (*RpbListBucketsResp, error) {
-	opts := []byte{}
+import (
+	"time"
+)
+
+// SetListingGuard makes ListBuckets, StreamBuckets and ListKeys fail with
+// ErrListingRefused unless called through AllowListing. Listing buckets or
+// keys walks every key of the cluster, and is best kept out of production
+// traffic.
+func (c *Client) SetListingGuard(guard bool) {
+	c.listingGuard = guard
+}
+
+// AllowListing returns a shallow copy of the client which lists buckets and
+// keys even if the listing guard is set. The copy shares the pool of the
+// original.
+func (c *Client) AllowListing() *Client {
+	copied := *c
+	copied.listingAllowed = true
+	return &copied
+}
+
+// listingRefused reports whether the listing guard refuses a listing.
+func (c *Client) listingRefused() bool {
+	return c.listingGuard && !c.listingAllowed
+}
+
+// NewListBucketsRequest prepares a ListBuckets request, streamed if stream is
+// set. timeout bounds the listing on the Riak side, unless zero.
+func (c *Client) NewListBucketsRequest(stream bool, timeout time.Duration) *RpbListBucketsReq {
+	return &RpbListBucketsReq{
+		Timeout: timeoutMillis(timeout),
+		Stream:  flag(stream),
+	}
+}
+
+func (c *Client) listBuckets(opts *RpbListBucketsReq) (*RpbListBucketsResp, error) {
+	if opts == nil {
+		opts = c.NewListBucketsRequest(false, 0)
+	}
+
+	if opts.GetStream() {
+		response := &RpbListBucketsResp{}
+		err := c.streamBuckets(opts, func(buckets [][]byte) error {
+			response.Buckets = append(response.Buckets, buckets...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	if c.listingRefused() {
+		return nil, ErrListingRefused
+	}
 
-	response, err := c.ReqResp(opts, "RpbListBucketsReq", true)
+	response, err := c.ReqResp(opts, "RpbListBucketsReq", false)
 	if err != nil {
 		return nil, err
 	}
@@ -12,6 +64,45 @@ func (c *Client) ListBuckets() (*RpbListBucketsResp, error) {
 	return response.(*RpbListBucketsResp), nil
 }
 
+// ListBuckets lists all buckets. It walks every key of the cluster, see
+// SetListingGuard.
+func (c *Client) ListBuckets() (*RpbListBucketsResp, error) {
+	return c.listBuckets(nil)
+}
+
+func (c *Client) streamBuckets(opts *RpbListBucketsReq, fn func(buckets [][]byte) error) (err error) {
+	if c.listingRefused() {
+		return ErrListingRefused
+	}
+
+	span := c.startSpan("StreamBuckets", opts, "RpbListBucketsReq")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	node, err := c.SelectNode()
+	if err != nil {
+		return err
+	}
+	span.SetAttribute(AttrNode, node.Addr())
+
+	size, err := node.reqBucketsStream(opts, fn)
+	span.SetAttribute(AttrResponseSize, size)
+	return err
+}
+
+// StreamBuckets lists all buckets like ListBuckets, but calls fn with each
+// batch of buckets as Riak sends them rather than collecting them first.
+// timeout bounds the listing on the Riak side, unless zero. If fn returns an
+// error the rest of the listing is discarded and the error returned.
+//
+// The listing is read on a connection of its own as fn returns, so a slow fn
+// holds Riak back rather than the client buffering the listing, and fn may
+// send requests of its own.
+func (c *Client) StreamBuckets(timeout time.Duration, fn func(buckets [][]byte) error) error {
+	return c.streamBuckets(c.NewListBucketsRequest(true, timeout), fn)
+}
+
 // NewListKeysRequest prepares a ListKeys request.
 func (c *Client) NewListKeysRequest(bucket string) *RpbListKeysReq {
 	return &RpbListKeysReq{
@@ -20,6 +111,9 @@ func (c *Client) NewListKeysRequest(bucket string) *RpbListKeysReq {
 }
 
 func (c *Client) listKeys(opts *RpbListKeysReq, bucket string) ([][]byte, error) {
+	if c.listingRefused() {
+		return nil, ErrListingRefused
+	}
 	if opts == nil {
 		opts = c.NewListKeysRequest(bucket)
 	}
@@ -34,7 +128,8 @@ func (c *Client) listKeys(opts *RpbListKeysReq, bucket string) ([][]byte, error)
 	return keys, nil
 }
 
-// ListKeys lists all keys from bucket.
+// ListKeys lists all keys from bucket. It walks every key of the cluster, see
+// SetListingGuard.
 func (c *Client) ListKeys(bucket string) ([][]byte, error) {
 	return c.listKeys(nil, bucket)
 }
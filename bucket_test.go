@@ -1,10 +1,16 @@
 package riakpbc
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestListBuckets(t *testing.T) {
@@ -90,3 +96,166 @@ func TestGetAndSetBucketsDo(t *testing.T) {
 
 	teardownData(t, riak)
 }
+
+func TestStreamBuckets(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	for _, bucket := range []string{"people", "pets", "places"} {
+		riak.StoreObject(bucket, "key", "value")
+	}
+
+	var streamed []string
+	err := riak.StreamBuckets(5*time.Second, func(buckets [][]byte) error {
+		for _, bucket := range buckets {
+			streamed = append(streamed, string(bucket))
+		}
+		return nil
+	})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"people", "pets", "places"}, streamed)
+
+	req := &RpbListBucketsReq{}
+	server.lastRequest("RpbListBucketsReq", req)
+	assert.T(t, req.GetStream())
+	assert.Equal(t, uint32(5000), req.GetTimeout())
+
+	// The rest of the stream is discarded, leaving the connection usable
+	stop := errors.New("stop")
+	calls := 0
+	err = riak.StreamBuckets(0, func(buckets [][]byte) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+
+	buckets, err := riak.ListBuckets()
+	assert.T(t, err == nil)
+	assert.Equal(t, 3, len(buckets.GetBuckets()))
+
+	// Requests sent from fn don't read the listing
+	var values []string
+	err = riak.StreamBuckets(0, func(buckets [][]byte) error {
+		obj, err := riak.FetchObject(string(buckets[0]), "key")
+		if err != nil {
+			return err
+		}
+		values = append(values, string(obj.GetContent()[0].GetValue()))
+		return nil
+	})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"value", "value", "value"}, values)
+
+	response, err := riak.Do(riak.NewListBucketsRequest(true, time.Second))
+	assert.T(t, err == nil)
+	assert.Equal(t, 3, len(response.(*RpbListBucketsResp).GetBuckets()))
+	server.lastRequest("RpbListBucketsReq", req)
+	assert.T(t, req.GetStream())
+
+	response, err = riak.Do(riak.NewListBucketsRequest(false, 0))
+	assert.T(t, err == nil)
+	assert.Equal(t, 3, len(response.(*RpbListBucketsResp).GetBuckets()))
+}
+
+func TestStreamBucketsWhileListing(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The listing holds back its last message until an object is fetched
+	fetched := make(chan struct{})
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		for {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			msg := make([]byte, binary.BigEndian.Uint32(header))
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+
+			switch numToCommand[int(msg[0])] {
+			case "RpbListBucketsReq":
+				writeTestMessage(conn, "RpbListBucketsResp", &RpbListBucketsResp{Buckets: [][]byte{[]byte("people")}})
+				<-fetched
+				writeTestMessage(conn, "RpbListBucketsResp", &RpbListBucketsResp{Done: proto.Bool(true)})
+			case "RpbGetReq":
+				writeTestMessage(conn, "RpbGetResp", &RpbGetResp{Content: []*RpbContent{{Value: []byte("value")}}})
+				close(fetched)
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	riak := NewClient([]string{listener.Addr().String()})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- riak.StreamBuckets(0, func(buckets [][]byte) error {
+			_, err := riak.FetchObject(string(buckets[0]), "key")
+			return err
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.T(t, err == nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetching from fn waited for the listing")
+	}
+}
+
+func TestListingGuard(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+	riak.StoreObject("people", "bob", "bob")
+
+	riak.SetListingGuard(true)
+	_, err := riak.ListBuckets()
+	assert.Equal(t, ErrListingRefused, err)
+	_, err = riak.ListKeys("people")
+	assert.Equal(t, ErrListingRefused, err)
+	_, err = riak.Do(riak.NewListKeysRequest("people"))
+	assert.Equal(t, ErrListingRefused, err)
+	err = riak.StreamBuckets(0, func([][]byte) error { return nil })
+	assert.Equal(t, ErrListingRefused, err)
+	_, err = riak.Do(riak.NewListBucketsRequest(true, 0))
+	assert.Equal(t, ErrListingRefused, err)
+
+	allowed := riak.AllowListing()
+	keys, err := allowed.ListKeys("people")
+	assert.T(t, err == nil)
+	assert.Equal(t, 1, len(keys))
+	buckets, err := allowed.ListBuckets()
+	assert.T(t, err == nil)
+	assert.Equal(t, 1, len(buckets.GetBuckets()))
+
+	// The original client stays guarded
+	_, err = riak.ListKeys("people")
+	assert.Equal(t, ErrListingRefused, err)
+
+	riak.SetListingGuard(false)
+	_, err = riak.ListKeys("people")
+	assert.T(t, err == nil)
+}
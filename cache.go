@@ -0,0 +1,184 @@
+package riakpbc
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// FetchIfModified fetches an object only if its vclock differs from vclock.
+// When it doesn't, unchanged is true and the response carries no content.
+func (c *Client) FetchIfModified(bucket, key string, vclock []byte, opts ...*GetOptions) (response *RpbGetResp, unchanged bool, err error) {
+	req := c.getRequest(bucket, key, opts)
+	req.IfModified = vclock
+
+	response, err = c.fetchObject("FetchIfModified", req, bucket, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return response, response.GetUnchanged(), nil
+}
+
+// ObjectCache keeps recently fetched objects on the client, revalidating them
+// against Riak with FetchIfModified so that unchanged values aren't sent
+// again. It holds at most size objects, evicting the least recently used.
+//
+// Responses returned from the cache are shared and must not be modified.
+type ObjectCache struct {
+	client  *Client
+	size    int
+	maxAge  time.Duration
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	sync.Mutex
+}
+
+type cacheEntry struct {
+	name      cacheKey
+	response  *RpbGetResp
+	validated time.Time
+}
+
+// cacheKey names a cached object by bucket and key along with the fetch
+// options which change the response, so that a head or tombstone fetch is
+// never served for a full one.
+type cacheKey struct {
+	bucket  string
+	key     string
+	head    bool
+	deleted bool
+	r       uint32
+	pr      uint32
+}
+
+func (cache *ObjectCache) cacheKey(bucket, key string, opts []*GetOptions) cacheKey {
+	req := cache.client.getRequest(bucket, key, opts)
+	return cacheKey{
+		bucket:  bucket,
+		key:     key,
+		head:    req.GetHead(),
+		deleted: req.GetDeletedvclock(),
+		r:       req.GetR(),
+		pr:      req.GetPr(),
+	}
+}
+
+// NewObjectCache returns an ObjectCache fetching through client.
+func NewObjectCache(client *Client, size int) *ObjectCache {
+	return &ObjectCache{
+		client:  client,
+		size:    size,
+		entries: map[cacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// SetMaxAge serves objects validated within maxAge from the cache without
+// asking Riak. Zero, the default, revalidates every fetch.
+func (cache *ObjectCache) SetMaxAge(maxAge time.Duration) {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.maxAge = maxAge
+}
+
+// Fetch returns an object like FetchObject, from the cache if Riak reports
+// that it is unchanged. Fetches with different head, deleted vclock or read
+// quorum options are cached apart.
+func (cache *ObjectCache) Fetch(bucket, key string, opts ...*GetOptions) (*RpbGetResp, error) {
+	name := cache.cacheKey(bucket, key, opts)
+
+	cache.Lock()
+	var cached *cacheEntry
+	if elem, ok := cache.entries[name]; ok {
+		cached = elem.Value.(*cacheEntry)
+		cache.lru.MoveToFront(elem)
+		if cache.maxAge > 0 && time.Since(cached.validated) < cache.maxAge {
+			cache.Unlock()
+			return cached.response, nil
+		}
+	}
+	cache.Unlock()
+
+	if cached == nil {
+		response, err := cache.client.FetchObject(bucket, key, opts...)
+		if err != nil {
+			return nil, err
+		}
+		cache.add(name, response)
+		return response, nil
+	}
+
+	response, unchanged, err := cache.client.FetchIfModified(bucket, key, cached.response.GetVclock(), opts...)
+	if err == ErrObjectNotFound {
+		cache.Invalidate(bucket, key)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if unchanged {
+		cache.Lock()
+		cached.validated = time.Now()
+		cache.Unlock()
+		return cached.response, nil
+	}
+
+	cache.add(name, response)
+	return response, nil
+}
+
+// add caches response under name, evicting the least recently used object
+// if the cache is full.
+func (cache *ObjectCache) add(name cacheKey, response *RpbGetResp) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.size <= 0 {
+		return
+	}
+
+	if elem, ok := cache.entries[name]; ok {
+		elem.Value = &cacheEntry{name: name, response: response, validated: time.Now()}
+		cache.lru.MoveToFront(elem)
+		return
+	}
+
+	cache.entries[name] = cache.lru.PushFront(&cacheEntry{name: name, response: response, validated: time.Now()})
+	for cache.lru.Len() > cache.size {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).name)
+	}
+}
+
+// Invalidate drops an object from the cache, whatever options it was fetched
+// with.
+func (cache *ObjectCache) Invalidate(bucket, key string) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for name, elem := range cache.entries {
+		if name.bucket == bucket && name.key == key {
+			cache.lru.Remove(elem)
+			delete(cache.entries, name)
+		}
+	}
+}
+
+// Clear drops every object from the cache.
+func (cache *ObjectCache) Clear() {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.entries = map[cacheKey]*list.Element{}
+	cache.lru.Init()
+}
+
+// Len returns the number of objects in the cache.
+func (cache *ObjectCache) Len() int {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.lru.Len()
+}
@@ -0,0 +1,147 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestFetchIfModified(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	resp, err := riak.StoreObject("bucket", "key", "first")
+	assert.T(t, err == nil)
+
+	obj, unchanged, err := riak.FetchIfModified("bucket", "key", resp.GetVclock())
+	assert.T(t, err == nil)
+	assert.T(t, unchanged)
+	assert.Equal(t, 0, len(obj.GetContent()))
+
+	obj, unchanged, err = riak.FetchIfModified("bucket", "key", []byte("stale"))
+	assert.T(t, err == nil)
+	assert.T(t, !unchanged)
+	assert.Equal(t, "first", string(obj.GetContent()[0].GetValue()))
+
+	_, _, err = riak.FetchIfModified("bucket", "missing", []byte("stale"))
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestObjectCache(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	_, err := riak.StoreObject("bucket", "a", "first")
+	assert.T(t, err == nil)
+
+	cache := NewObjectCache(riak, 2)
+
+	first, err := cache.Fetch("bucket", "a")
+	assert.T(t, err == nil)
+	assert.Equal(t, "first", string(first.GetContent()[0].GetValue()))
+	assert.Equal(t, 1, cache.Len())
+
+	// Revalidated with the cached vclock, and served from the cache
+	again, err := cache.Fetch("bucket", "a")
+	assert.T(t, err == nil)
+	assert.T(t, again == first)
+	req := &RpbGetReq{}
+	server.lastRequest("RpbGetReq", req)
+	assert.Equal(t, first.GetVclock(), req.GetIfModified())
+
+	// Changed objects are fetched again
+	_, err = riak.StoreObject("bucket", "a", "second")
+	assert.T(t, err == nil)
+	changed, err := cache.Fetch("bucket", "a")
+	assert.T(t, err == nil)
+	assert.Equal(t, "second", string(changed.GetContent()[0].GetValue()))
+
+	// The least recently used object is evicted
+	riak.StoreObject("bucket", "b", "b")
+	riak.StoreObject("bucket", "c", "c")
+	cache.Fetch("bucket", "b")
+	cache.Fetch("bucket", "a")
+	cache.Fetch("bucket", "c")
+	assert.Equal(t, 2, cache.Len())
+	cache.Lock()
+	_, cachedA := cache.entries[cacheKey{bucket: "bucket", key: "a"}]
+	_, cachedB := cache.entries[cacheKey{bucket: "bucket", key: "b"}]
+	cache.Unlock()
+	assert.T(t, cachedA)
+	assert.T(t, !cachedB)
+
+	// Deleted objects are dropped
+	riak.DeleteObject("bucket", "a")
+	_, err = cache.Fetch("bucket", "a")
+	assert.Equal(t, ErrObjectNotFound, err)
+	assert.Equal(t, 1, cache.Len())
+
+	cache.Invalidate("bucket", "c")
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestObjectCacheMaxAge(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	riak.StoreObject("bucket", "key", "first")
+
+	cache := NewObjectCache(riak, 10)
+	cache.SetMaxAge(time.Hour)
+
+	first, err := cache.Fetch("bucket", "key")
+	assert.T(t, err == nil)
+
+	// Within the max age Riak isn't asked at all
+	riak.StoreObject("bucket", "key", "second")
+	cached, err := cache.Fetch("bucket", "key")
+	assert.T(t, err == nil)
+	assert.T(t, cached == first)
+	req := &RpbGetReq{}
+	server.lastRequest("RpbGetReq", req)
+	assert.T(t, req.IfModified == nil)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+	fresh, err := cache.Fetch("bucket", "key")
+	assert.T(t, err == nil)
+	assert.Equal(t, "second", string(fresh.GetContent()[0].GetValue()))
+}
+
+func TestObjectCacheOptions(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	riak.StoreObject("bucket", "key", "value")
+	cache := NewObjectCache(riak, 10)
+	cache.SetMaxAge(time.Minute)
+
+	// A head fetch isn't served for a full one
+	obj, err := cache.Fetch("bucket", "key", &GetOptions{Head: true})
+	assert.T(t, err == nil)
+	assert.Equal(t, 0, len(obj.GetContent()[0].GetValue()))
+
+	obj, err = cache.Fetch("bucket", "key")
+	assert.T(t, err == nil)
+	assert.Equal(t, "value", string(obj.GetContent()[0].GetValue()))
+
+	obj, err = cache.Fetch("bucket", "key", &GetOptions{Head: true})
+	assert.T(t, err == nil)
+	assert.Equal(t, 0, len(obj.GetContent()[0].GetValue()))
+	assert.Equal(t, 2, cache.Len())
+
+	cache.Invalidate("bucket", "key")
+	assert.Equal(t, 0, cache.Len())
+}
@@ -1,6 +1,7 @@
 package riakpbc
 
 import (
+	"context"
 	"log"
 	"time"
 )
@@ -9,9 +10,21 @@ type Client struct {
 	cluster       []string
 	pool          *Pool
 	Coder         *Coder // Coder for (un)marshalling data
+	coders        map[string]*Coder
+	logger        Logger
 	logging       bool
 	pingFrequency int
 	closed        chan struct{}
+	tracer        Tracer
+	ctx           context.Context
+
+	compressor        Compressor
+	compressThreshold int
+	siblingResolver   SiblingResolver
+	linkIndexing      bool
+
+	listingGuard   bool
+	listingAllowed bool
 }
 
 // NewClient accepts a slice of node address strings and returns a Client object.
@@ -48,12 +61,11 @@ func (c *Client) Dial() error {
 	c.closed = make(chan struct{})
 
 	for _, node := range c.pool.nodes {
+		node.Lock()
 		err := node.Dial()
+		node.Unlock()
 		if err != nil {
 			node.RecordError(10.0)
-			if c.LoggingEnabled() {
-				log.Print("[POOL] Error: ", err)
-			}
 		}
 	}
 
@@ -61,6 +73,8 @@ func (c *Client) Dial() error {
 		return ErrZeroNodes
 	}
 
+	c.pool.log().Info("client dialed", "nodes", c.pool.Size())
+
 	go c.BackgroundNodePing()
 
 	return nil
@@ -70,6 +84,7 @@ func (c *Client) Dial() error {
 func (c *Client) Close() {
 	close(c.closed)
 	c.pool.Close()
+	c.pool.log().Info("client closed")
 }
 
 func (c *Client) BackgroundNodePing() {
@@ -90,6 +105,35 @@ func (c *Client) SelectNode() (*Node, error) {
 	return c.pool.SelectNode()
 }
 
+// SetBreakerConfig sets the circuit breaker thresholds of every node, see
+// *Pool.SetBreakerConfig()
+func (c *Client) SetBreakerConfig(config *BreakerConfig) {
+	c.pool.SetBreakerConfig(config)
+}
+
+// OnNodeStateChange registers a callback fired whenever a node changes state,
+// see *Pool.OnStateChange()
+func (c *Client) OnNodeStateChange(fn StateChangeFunc) {
+	c.pool.OnStateChange(fn)
+}
+
+// SetMetrics sets the Metrics notified after every request, see
+// *Pool.SetMetrics()
+func (c *Client) SetMetrics(metrics Metrics) {
+	c.pool.SetMetrics(metrics)
+}
+
+// EnablePipelining lets up to depth requests share a connection to each node,
+// see *Node.EnablePipelining()
+func (c *Client) EnablePipelining(depth int) {
+	c.pool.EnablePipelining(depth)
+}
+
+// DisablePipelining switches the client back to one request per round trip.
+func (c *Client) DisablePipelining() {
+	c.pool.DisablePipelining()
+}
+
 // Pool returns the pool associated with the client.
 func (c *Client) Pool() *Pool {
 	return c.pool
@@ -98,6 +142,9 @@ func (c *Client) Pool() *Pool {
 // Do executes a prepared query and returns the results.
 func (c *Client) Do(opts interface{}) (interface{}, error) {
 	// Bucket
+	if _, ok := opts.(*RpbListBucketsReq); ok {
+		return c.listBuckets(opts.(*RpbListBucketsReq))
+	}
 	if _, ok := opts.(*RpbListKeysReq); ok {
 		return c.listKeys(opts.(*RpbListKeysReq), string(opts.(*RpbListKeysReq).GetBucket()))
 	}
@@ -112,10 +159,10 @@ func (c *Client) Do(opts interface{}) (interface{}, error) {
 
 	// Object
 	if _, ok := opts.(*RpbGetReq); ok {
-		return c.fetchObject(opts.(*RpbGetReq), string(opts.(*RpbGetReq).GetBucket()), string(opts.(*RpbGetReq).GetKey()))
+		return c.fetchObject("FetchObject", opts.(*RpbGetReq), string(opts.(*RpbGetReq).GetBucket()), string(opts.(*RpbGetReq).GetKey()))
 	}
 	if _, ok := opts.(*RpbDelReq); ok {
-		return c.deleteObject(opts.(*RpbDelReq), string(opts.(*RpbDelReq).GetBucket()), string(opts.(*RpbDelReq).GetKey()))
+		return c.deleteObject("DeleteObject", opts.(*RpbDelReq), string(opts.(*RpbDelReq).GetBucket()), string(opts.(*RpbDelReq).GetKey()))
 	}
 
 	// Query
@@ -140,7 +187,7 @@ func (c *Client) Do(opts interface{}) (interface{}, error) {
 // DoObject executes a prepared query with data and returns the results.
 func (c *Client) DoObject(opts interface{}, in interface{}) (interface{}, error) {
 	if _, ok := opts.(*RpbPutReq); ok {
-		return c.storeObject(opts.(*RpbPutReq), string(opts.(*RpbPutReq).GetBucket()), string(opts.(*RpbPutReq).GetKey()), in)
+		return c.storeObject("StoreObject", opts.(*RpbPutReq), string(opts.(*RpbPutReq).GetBucket()), string(opts.(*RpbPutReq).GetKey()), in)
 	}
 
 	return nil, nil
@@ -149,10 +196,10 @@ func (c *Client) DoObject(opts interface{}, in interface{}) (interface{}, error)
 // DoStruct executes a prepared query on a struct with the coder and returns the results.
 func (c *Client) DoStruct(opts interface{}, in interface{}) (interface{}, error) {
 	if _, ok := opts.(*RpbGetReq); ok {
-		return c.fetchStruct(opts.(*RpbGetReq), string(opts.(*RpbGetReq).GetBucket()), string(opts.(*RpbGetReq).GetKey()), in)
+		return c.fetchStruct("FetchStruct", opts.(*RpbGetReq), string(opts.(*RpbGetReq).GetBucket()), string(opts.(*RpbGetReq).GetKey()), in)
 	}
 	if _, ok := opts.(*RpbPutReq); ok {
-		return c.storeStruct(opts.(*RpbPutReq), string(opts.(*RpbPutReq).GetBucket()), string(opts.(*RpbPutReq).GetKey()), in)
+		return c.storeStruct("StoreStruct", opts.(*RpbPutReq), string(opts.(*RpbPutReq).GetBucket()), string(opts.(*RpbPutReq).GetKey()), in)
 	}
 
 	return nil, nil
@@ -160,32 +207,76 @@ func (c *Client) DoStruct(opts interface{}, in interface{}) (interface{}, error)
 
 // ReqResp is the top level interface for the client for a bulk of Riak operations
 func (c *Client) ReqResp(reqstruct interface{}, structname string, raw bool) (response interface{}, err error) {
+	return c.reqResp(operationName(structname), reqstruct, structname, raw)
+}
+
+// reqResp is ReqResp, tracing the request as the client operation sending
+// it.
+func (c *Client) reqResp(operation string, reqstruct interface{}, structname string, raw bool) (response interface{}, err error) {
+	span := c.startSpan(operation, reqstruct, structname)
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	node, err := c.SelectNode()
 	if err != nil {
 		return nil, err
 	}
-	return node.ReqResp(reqstruct, structname, raw)
+	span.SetAttribute(AttrNode, node.Addr())
+
+	response, size, err := node.reqResp(reqstruct, structname, raw)
+	span.SetAttribute(AttrResponseSize, size)
+	return response, err
 }
 
 // ReqMultiResp is the top level interface for the client for the few
 // operations which have to hit the server multiple times to guarantee
 // a complete response: List keys, Map Reduce, etc.
 func (c *Client) ReqMultiResp(reqstruct interface{}, structname string) (response interface{}, err error) {
+	span := c.startSpan(operationName(structname), reqstruct, structname)
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	node, err := c.SelectNode()
 	if err != nil {
 		return nil, err
 	}
-	return node.ReqMultiResp(reqstruct, structname)
+	span.SetAttribute(AttrNode, node.Addr())
+
+	response, size, err := node.reqMultiResp(reqstruct, structname)
+	span.SetAttribute(AttrResponseSize, size)
+	return response, err
+}
+
+// SetLogger sets the Logger of the client, its pool and nodes, and enables
+// logging.
+func (c *Client) SetLogger(logger Logger) {
+	c.logger = logger
+	c.EnableLogging()
+}
+
+// SetSlowRequestThreshold sets the latency above which requests are logged as
+// slow, see *Pool.SetSlowRequestThreshold()
+func (c *Client) SetSlowRequestThreshold(threshold time.Duration) {
+	c.pool.SetSlowRequestThreshold(threshold)
 }
 
 // Enables logging for client
+//
+// Without a Logger set, the standard library logger is used.
 func (c *Client) EnableLogging() {
 	c.logging = true
+	if c.logger == nil {
+		c.logger = NewStdLogger(log.Default())
+	}
+	c.pool.SetLogger(c.logger)
 }
 
 // Disables logging for client
 func (c *Client) DisableLogging() {
 	c.logging = false
+	c.pool.SetLogger(nopLogger{})
 }
 
 // Tests whether logging is enabled for client
@@ -6,12 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
-	"strings"
 )
 
-// typeOfBytes is a special check against Slices of []byte.
-var typeOfBytes = reflect.TypeOf([]byte(nil))
+// isBytes reports whether t is a slice of bytes, []byte or a named type such
+// as json.RawMessage.
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
 
 // MarshalMethod is the method signature of a marshaller.
 type MarshalMethod func(interface{}, *RpbContent) error
@@ -22,7 +23,8 @@ type UnmarshalMethod func([]byte, interface{}) error
 // Coder contains a tag, marshaller, and unmarshaller.
 // It's primary duty is to convert data from `tag` format to and from a composed struct.
 type Coder struct {
-	tag          string          // the tag to match for the marshaller
+	tag          string          // the tag to match for the marshaller, empty to always match
+	contentType  string          // the content type the marshaller produces, if known
 	marshaller   MarshalMethod   // the method to run on the data
 	unmarshaller UnmarshalMethod // the method to extra the data
 }
@@ -50,19 +52,35 @@ func JsonUnmarshaller(in []byte, out interface{}) error {
 }
 
 // NewCoder requires a tag and MarshalMethod.
+//
+// Structs are only passed to the marshaller if one of their fields carries
+// the tag, unless the tag is empty.
 func NewCoder(tag string, marshaller MarshalMethod, unmarshaller UnmarshalMethod) *Coder {
 	self := new(Coder)
 	self.tag = tag
+	self.contentType = tagContentTypes[tag]
 	self.marshaller = marshaller
 	self.unmarshaller = unmarshaller
 	return self
 }
 
+// ContentType returns the content type the Coder marshals to, or an empty
+// string if it isn't known.
+func (self *Coder) ContentType() string {
+	return self.contentType
+}
+
 // Marshal takes a struct with `riak` tagged fields and builds the correct
 // RpbContent to send along to Riak.
 //
 // Any fields of type string are set as a _bin index, and fields of any
-// int type set to an _int index.
+// int type set to an _int index. Slice, array and map fields set one index
+// value per element, or per key for maps; repeated values are dropped.
+//
+// Types implementing IndexEncoder encode themselves. Bools are set as a 0 or
+// 1 _int index, floats as an _int of the value times 10^precision, and
+// time.Time as an _int of unix seconds unless the tag says otherwise. Any
+// other type is an error.
 //
 // Examples:
 //
@@ -75,6 +93,49 @@ func NewCoder(tag string, marshaller MarshalMethod, unmarshaller UnmarshalMethod
 //  // Field is a _bin index and also a json field in the actual data.
 //  Field string `json:"field" riak:"index"`
 //
+//  // Field sets one tags_bin value per element, naming the index explicitly
+//  // rather than after the field.
+//  Field []string `riak:"index=tags_bin"`
+//
+//  // Field is an _int index of unix milliseconds; time=unix (the default)
+//  // sets seconds, time=rfc3339 a _bin index.
+//  Field time.Time `riak:"index,time=millis"`
+//
+//  // Field is an _int index of cents.
+//  Field float64 `riak:"index,precision=2"`
+//
+// Fields can also carry object metadata rather than indexes:
+//
+//  // A map[string]string holds all usermeta, a string or []byte field the
+//  // usermeta entry named by the tag value or the lowercased field name.
+//  Meta map[string]string `riak:"meta"`
+//  Owner string `riak:"meta=owner"`
+//
+//  // Links to the keys in bucket people, tagged friend. Fields may be a
+//  // string, []string or []*RpbLink; without a bucket option string values
+//  // are read as "bucket/key".
+//  Friends []string `riak:"link,bucket=people,tag=friend"`
+//
+//  // Override the content type, charset and content encoding set by the
+//  // marshaller.
+//  Type string `riak:"contenttype"`
+//  Charset string `riak:"charset"`
+//  Encoding string `riak:"contentencoding"`
+//
+//  // The key and vclock of the object. These are not part of RpbContent;
+//  // StoreStruct and FetchStruct read and fill them.
+//  Key string `riak:"key"`
+//  Vclock []byte `riak:"vclock"`
+//
+// Tag the metadata fields `json:"-"` to keep them out of the marshalled value.
+//
+// Embedded structs, nested struct fields and non-nil pointers to structs are
+// searched for tagged fields too. Tag a nested field with a prefix to keep
+// its indexes apart from those of other fields of the same type:
+//
+//  // Home's City field is the home_city_bin index.
+//  Home Address `riak:"prefix=home_"`
+//
 // TODO: The PBC int interface doesn't seem to work directly with byte data, even though the API specification is byte data.
 // Needs to be investigated further.
 //
@@ -92,121 +153,47 @@ func (self *Coder) Marshal(data interface{}) (*RpbContent, error) {
 	e := t.Elem()
 	switch e.Kind() {
 	case reflect.Struct:
-		matched := false
-
-		for i := 0; i < e.NumField(); i++ {
-			val := e.Field(i).Interface()
-			fld := e.Type().Field(i)
-			knd := e.Field(i).Kind()
-			tag := fld.Tag
-
-			// Skip anonymous fields
-			if fld.Anonymous {
-				continue
-			}
+		matched := self.tag == ""
+		var contentType, charset, contentEncoding []byte
 
+		err := walkFields(e, func(fld reflect.StructField, v reflect.Value, directives []*riakTag, prefix string) error {
 			// Match marshaller tag
-			if matched == false && tag.Get(self.tag) != "" {
+			if matched == false && fld.Tag.Get(self.tag) != "" {
 				matched = true
 			}
 
-			if tdata := tag.Get("riak"); tdata != "" {
-				for _, tfield := range strings.Split(tdata, ",") {
-					switch tfield {
-					case "index":
-						index := &RpbPair{}
-						var key string
-						switch knd {
-						case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-							key = fld.Name + "_int"
-							switch knd {
-							case reflect.Int:
-								//buf := make([]byte, 10)
-								//binary.PutVarint(buf, int64(val.(int)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(int))))
-								break
-							case reflect.Int8:
-								//buf := make([]byte, 2)
-								//binary.PutVarint(buf, int64(val.(int8)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(int8))))
-								break
-							case reflect.Int16:
-								//buf := make([]byte, 3)
-								//binary.PutVarint(buf, int64(val.(int16)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(int16))))
-								break
-							case reflect.Int32:
-								//buf := make([]byte, 5)
-								//binary.PutVarint(buf, int64(val.(int32)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(int32))))
-								break
-							case reflect.Int64:
-								//buf := make([]byte, 10)
-								//binary.PutVarint(buf, int64(val.(int64)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(int64))))
-								break
-							}
-							index.Key = []byte(strings.ToLower(key))
-							break
-						case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-							key = fld.Name + "_int"
-							switch knd {
-							case reflect.Uint:
-								//buf := make([]byte, 10)
-								//binary.PutUvarint(buf, uint64(val.(uint)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(uint))))
-								break
-							case reflect.Uint8:
-								//buf := make([]byte, 2)
-								//binary.PutUvarint(buf, uint64(val.(uint8)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(uint8))))
-								break
-							case reflect.Uint16:
-								//buf := make([]byte, 3)
-								//binary.PutUvarint(buf, uint64(val.(uint16)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(uint16))))
-								break
-							case reflect.Uint32:
-								//buf := make([]byte, 5)
-								//binary.PutUvarint(buf, uint64(val.(uint32)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(uint32))))
-								break
-							case reflect.Uint64:
-								//buf := make([]byte, 10)
-								//binary.PutUvarint(buf, uint64(val.(uint64)))
-								//index.Value = buf
-								index.Value = []byte(strconv.Itoa(int(val.(uint64))))
-								break
-							}
-							index.Key = []byte(strings.ToLower(key))
-							break
-						case reflect.String:
-							key = fld.Name + "_bin"
-							index.Key = []byte(strings.ToLower(key))
-							index.Value = []byte(val.(string))
-							break
-						case reflect.Slice:
-							if fld.Type == typeOfBytes {
-								key = fld.Name + "_bin"
-								index.Key = []byte(strings.ToLower(key))
-								index.Value = []byte(val.([]byte))
-							}
-							break
-						}
-						out.Indexes = append(out.Indexes, index)
-						break
+			for _, directive := range directives {
+				switch directive.name {
+				case "index":
+					indexes, err := marshalIndex(fld, v, directive, prefix)
+					if err != nil {
+						return err
 					}
+					out.Indexes = append(out.Indexes, indexes...)
+				case "meta":
+					pairs, err := marshalMeta(fld, v, directive)
+					if err != nil {
+						return err
+					}
+					out.Usermeta = append(out.Usermeta, pairs...)
+				case "link":
+					links, err := marshalLinks(fld, v, directive)
+					if err != nil {
+						return err
+					}
+					out.Links = append(out.Links, links...)
+				case "contenttype":
+					contentType = fieldBytes(v)
+				case "charset":
+					charset = fieldBytes(v)
+				case "contentencoding":
+					contentEncoding = fieldBytes(v)
 				}
 			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		// Automatically marshal structures
@@ -215,6 +202,19 @@ func (self *Coder) Marshal(data interface{}) (*RpbContent, error) {
 				return nil, err
 			}
 		}
+
+		out.Indexes = uniqueIndexes(out.Indexes)
+
+		// Tagged fields take precedence over what the marshaller set
+		if len(contentType) > 0 {
+			out.ContentType = contentType
+		}
+		if len(charset) > 0 {
+			out.Charset = charset
+		}
+		if len(contentEncoding) > 0 {
+			out.ContentEncoding = contentEncoding
+		}
 		break
 	default:
 		return nil, errors.New("Marshal expected a struct")
@@ -227,3 +227,35 @@ func (self *Coder) Marshal(data interface{}) (*RpbContent, error) {
 func (self *Coder) Unmarshal(in []byte, data interface{}) error {
 	return self.unmarshaller(in, data)
 }
+
+// UnmarshalContent unwraps the value of content into the passed structure,
+// then fills its `meta`, `link`, `contenttype`, `charset` and
+// `contentencoding` tagged fields from the content's metadata.
+func (self *Coder) UnmarshalContent(content *RpbContent, data interface{}) error {
+	if err := self.Unmarshal(content.GetValue(), data); err != nil {
+		return err
+	}
+
+	e, err := structElem(data)
+	if err != nil {
+		return err
+	}
+
+	return walkFields(e, func(fld reflect.StructField, v reflect.Value, directives []*riakTag, prefix string) error {
+		for _, directive := range directives {
+			switch directive.name {
+			case "meta":
+				unmarshalMeta(v, directive, fld, content.GetUsermeta())
+			case "link":
+				unmarshalLinks(v, directive, content.GetLinks())
+			case "contenttype":
+				setFieldBytes(v, content.GetContentType())
+			case "charset":
+				setFieldBytes(v, content.GetCharset())
+			case "contentencoding":
+				setFieldBytes(v, content.GetContentEncoding())
+			}
+		}
+		return nil
+	})
+}
@@ -2,7 +2,11 @@ package riakpbc
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type EncodeData struct {
@@ -76,3 +80,311 @@ func TestCoder(t *testing.T) {
 		t.Errorf("Expected %s, got %s", data.Email, result.Email)
 	}
 }
+
+type MetaData struct {
+	Name     string            `json:"name"`
+	Key      string            `json:"-" riak:"key"`
+	Vclock   []byte            `json:"-" riak:"vclock"`
+	Meta     map[string]string `json:"-" riak:"meta"`
+	Owner    string            `json:"-" riak:"meta=owner"`
+	Friends  []string          `json:"-" riak:"link,bucket=people,tag=friend"`
+	Parent   string            `json:"-" riak:"link,tag=parent"`
+	Type     string            `json:"-" riak:"contenttype"`
+	Encoding string            `json:"-" riak:"contentencoding"`
+}
+
+func TestCoderMetadata(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	data := &MetaData{
+		Name:     "riak",
+		Key:      "riakkey",
+		Vclock:   []byte("vclock"),
+		Meta:     map[string]string{"b": "2", "a": "1"},
+		Owner:    "basho",
+		Friends:  []string{"bob", "alice"},
+		Parent:   "orgs/basho",
+		Type:     "application/vnd.riak+json",
+		Encoding: "identity",
+	}
+
+	content, err := e.Marshal(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(content.GetContentType()) != "application/vnd.riak+json" {
+		t.Errorf("Expected tagged content type, got %s", content.GetContentType())
+	}
+	if string(content.GetContentEncoding()) != "identity" {
+		t.Errorf("Expected identity, got %s", content.GetContentEncoding())
+	}
+	if len(content.GetUsermeta()) != 3 || string(content.GetUsermeta()[0].GetKey()) != "a" || string(content.GetUsermeta()[2].GetKey()) != "owner" {
+		t.Errorf("Unexpected usermeta %v", content.GetUsermeta())
+	}
+	if len(content.GetLinks()) != 3 {
+		t.Fatalf("Expected 3 links, got %d", len(content.GetLinks()))
+	}
+	if l := content.GetLinks()[2]; string(l.GetBucket()) != "orgs" || string(l.GetKey()) != "basho" || string(l.GetTag()) != "parent" {
+		t.Errorf("Unexpected parent link %v", l)
+	}
+	if structKey(data) != "riakkey" || string(structVclock(data)) != "vclock" {
+		t.Error("Expected key and vclock fields")
+	}
+
+	result := &MetaData{}
+	if err := e.UnmarshalContent(content, result); err != nil {
+		t.Fatal(err.Error())
+	}
+	setStructObject(result, "riakkey", []byte("vclock"))
+
+	if result.Name != "riak" || result.Key != "riakkey" || string(result.Vclock) != "vclock" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Meta["a"] != "1" || result.Owner != "basho" {
+		t.Errorf("Unexpected usermeta %+v", result)
+	}
+	if len(result.Friends) != 2 || result.Friends[0] != "bob" || result.Parent != "orgs/basho" {
+		t.Errorf("Unexpected links %+v", result)
+	}
+	if result.Type != "application/vnd.riak+json" || result.Encoding != "identity" {
+		t.Errorf("Unexpected content fields %+v", result)
+	}
+}
+
+type MultiIndexData struct {
+	Tags    []string        `json:"tags" riak:"index=tags_bin"`
+	Scores  [3]int          `json:"scores" riak:"index"`
+	Members map[string]bool `json:"members" riak:"index=member"`
+	Empty   []string        `json:"empty" riak:"index"`
+}
+
+func TestCoderMultiValuedIndexes(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	data := &MultiIndexData{
+		Tags:    []string{"go", "riak", "go"},
+		Scores:  [3]int{10, 20, 10},
+		Members: map[string]bool{"bob": true, "alice": true},
+	}
+
+	content, err := e.Marshal(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"tags_bin=go",
+		"tags_bin=riak",
+		"scores_int=10",
+		"scores_int=20",
+		"member_bin=alice",
+		"member_bin=bob",
+	}
+	indexes := content.GetIndexes()
+	if len(indexes) != len(expected) {
+		t.Fatalf("Expected %d indexes, got %d", len(expected), len(indexes))
+	}
+	for i, index := range indexes {
+		if got := string(index.GetKey()) + "=" + string(index.GetValue()); got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+}
+
+type Version struct {
+	Major, Minor int
+}
+
+func (v Version) EncodeIndex() (string, []byte, error) {
+	return IndexBin, []byte(fmt.Sprintf("%03d.%03d", v.Major, v.Minor)), nil
+}
+
+type TypedIndexData struct {
+	Created  time.Time `json:"created" riak:"index,time=millis"`
+	Updated  time.Time `json:"updated" riak:"index,time=rfc3339"`
+	Active   bool      `json:"active" riak:"index"`
+	Price    float64   `json:"price" riak:"index,precision=2"`
+	Version  Version   `json:"version" riak:"index"`
+	Optional *int      `json:"optional" riak:"index"`
+}
+
+func TestCoderTypedIndexes(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	when := time.Date(2013, 8, 3, 12, 0, 0, 0, time.UTC)
+	data := &TypedIndexData{
+		Created: when,
+		Updated: when,
+		Active:  true,
+		Price:   12.34,
+		Version: Version{1, 2},
+	}
+
+	content, err := e.Marshal(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"created_int=1375531200000",
+		"updated_bin=2013-08-03T12:00:00Z",
+		"active_int=1",
+		"price_int=1234",
+		"version_bin=001.002",
+	}
+	indexes := content.GetIndexes()
+	if len(indexes) != len(expected) {
+		t.Fatalf("Expected %d indexes, got %d", len(expected), len(indexes))
+	}
+	for i, index := range indexes {
+		if got := string(index.GetKey()) + "=" + string(index.GetValue()); got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+}
+
+type FloatIndexData struct {
+	Value float64 `json:"value" riak:"index,precision=0"`
+}
+
+func TestCoderFloatIndexRange(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	below := math.Nextafter(1<<63, 0)
+	content, err := e.Marshal(&FloatIndexData{Value: below})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := string(content.GetIndexes()[0].GetValue()); got != strconv.FormatInt(int64(below), 10) {
+		t.Errorf("Expected %d, got %s", int64(below), got)
+	}
+
+	// Scaled values which don't fit an int64 are refused
+	for _, value := range []float64{1 << 63, -(1 << 63), 1e300, math.Inf(1), math.NaN()} {
+		if _, err := e.Marshal(&FloatIndexData{Value: value}); err == nil {
+			t.Errorf("Expected an error indexing %v", value)
+		}
+	}
+}
+
+type UnsupportedIndexData struct {
+	Channel chan string `json:"-" riak:"index"`
+}
+
+func TestCoderUnsupportedIndex(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	if _, err := e.Marshal(&UnsupportedIndexData{}); err == nil {
+		t.Error("Expected an error indexing a channel")
+	}
+}
+
+type BaseData struct {
+	Key     string `json:"-" riak:"key"`
+	Created int64  `json:"created" riak:"index"`
+}
+
+type AddressData struct {
+	City string `json:"city" riak:"index"`
+}
+
+type NestedData struct {
+	BaseData
+	Name  string       `json:"name" riak:"index"`
+	Home  AddressData  `json:"home" riak:"prefix=home_"`
+	Work  *AddressData `json:"work" riak:"prefix=work_"`
+	Other *AddressData `json:"other"`
+}
+
+func TestCoderNestedStructs(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	data := &NestedData{
+		BaseData: BaseData{Key: "nestedkey", Created: 1375531200},
+		Name:     "riak",
+		Home:     AddressData{City: "Cambridge"},
+		Work:     &AddressData{City: "Boston"},
+	}
+
+	content, err := e.Marshal(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"created_int=1375531200",
+		"name_bin=riak",
+		"home_city_bin=Cambridge",
+		"work_city_bin=Boston",
+	}
+	indexes := content.GetIndexes()
+	if len(indexes) != len(expected) {
+		t.Fatalf("Expected %d indexes, got %d", len(expected), len(indexes))
+	}
+	for i, index := range indexes {
+		if got := string(index.GetKey()) + "=" + string(index.GetValue()); got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+
+	if structKey(data) != "nestedkey" {
+		t.Errorf("Expected the embedded key field, got %s", structKey(data))
+	}
+}
+
+type NamedBytes []byte
+
+type NamedBytesData struct {
+	Name   string          `json:"name"`
+	Key    NamedBytes      `json:"-" riak:"key"`
+	Vclock NamedBytes      `json:"-" riak:"vclock"`
+	Raw    NamedBytes      `json:"-" riak:"index"`
+	Doc    json.RawMessage `json:"-" riak:"index=doc"`
+	Owner  NamedBytes      `json:"-" riak:"meta=owner"`
+}
+
+func TestCoderNamedBytes(t *testing.T) {
+	e := NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+
+	data := &NamedBytesData{
+		Name:   "riak",
+		Key:    NamedBytes("riakkey"),
+		Vclock: NamedBytes("vclock"),
+		Raw:    NamedBytes("ab"),
+		Doc:    json.RawMessage(`{}`),
+		Owner:  NamedBytes("basho"),
+	}
+
+	content, err := e.Marshal(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"raw_bin=ab", "doc_bin={}"}
+	indexes := content.GetIndexes()
+	if len(indexes) != len(expected) {
+		t.Fatalf("Expected %d indexes, got %d", len(expected), len(indexes))
+	}
+	for i, index := range indexes {
+		if got := string(index.GetKey()) + "=" + string(index.GetValue()); got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+	if len(content.GetUsermeta()) != 1 || string(content.GetUsermeta()[0].GetValue()) != "basho" {
+		t.Errorf("Unexpected usermeta %v", content.GetUsermeta())
+	}
+	if structKey(data) != "riakkey" || string(structVclock(data)) != "vclock" {
+		t.Error("Expected key and vclock fields")
+	}
+
+	result := &NamedBytesData{}
+	if err := e.UnmarshalContent(content, result); err != nil {
+		t.Fatal(err.Error())
+	}
+	setStructObject(result, "riakkey", []byte("vclock"))
+
+	if string(result.Key) != "riakkey" || string(result.Vclock) != "vclock" || string(result.Owner) != "basho" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
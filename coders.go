@@ -0,0 +1,184 @@
+package riakpbc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+	"mime"
+)
+
+// Content types set by the built in marshallers.
+const (
+	ContentTypeJson     = "application/json"
+	ContentTypeGob      = "application/x-gob"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgpack  = "application/x-msgpack"
+	ContentTypeCbor     = "application/cbor"
+)
+
+// tagContentTypes lets NewCoder tell the content type of the built in
+// marshallers from their tag.
+var tagContentTypes = map[string]string{
+	"json":     ContentTypeJson,
+	"protobuf": ContentTypeProtobuf,
+	"msgpack":  ContentTypeMsgpack,
+	"cbor":     ContentTypeCbor,
+}
+
+// marshalValue unwraps the pointer to an interface Coder.Marshal hands to
+// its marshaller, which encoders other than encoding/json don't see through.
+func marshalValue(in interface{}) interface{} {
+	if p, ok := in.(*interface{}); ok {
+		return *p
+	}
+	return in
+}
+
+// GobMarshaller is a MarshalMethod encoding with encoding/gob.
+func GobMarshaller(in interface{}, out *RpbContent) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(marshalValue(in)); err != nil {
+		return err
+	}
+	out.Value = buf.Bytes()
+	out.ContentType = []byte(ContentTypeGob)
+	return nil
+}
+
+// GobUnmarshaller is an UnmarshalMethod decoding with encoding/gob.
+func GobUnmarshaller(in []byte, out interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(in)).Decode(out)
+}
+
+// ProtobufMarshaller is a MarshalMethod encoding protocol buffer messages.
+func ProtobufMarshaller(in interface{}, out *RpbContent) error {
+	msg, ok := marshalValue(in).(proto.Message)
+	if !ok {
+		return errors.New("ProtobufMarshaller expected a proto.Message")
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	out.Value = data
+	out.ContentType = []byte(ContentTypeProtobuf)
+	return nil
+}
+
+// ProtobufUnmarshaller is an UnmarshalMethod decoding protocol buffer messages.
+func ProtobufUnmarshaller(in []byte, out interface{}) error {
+	msg, ok := out.(proto.Message)
+	if !ok {
+		return errors.New("ProtobufUnmarshaller expected a proto.Message")
+	}
+	return proto.Unmarshal(in, msg)
+}
+
+// MsgpackMarshaller is a MarshalMethod encoding MessagePack.
+func MsgpackMarshaller(in interface{}, out *RpbContent) error {
+	data, err := msgpack.Marshal(marshalValue(in))
+	if err != nil {
+		return err
+	}
+	out.Value = data
+	out.ContentType = []byte(ContentTypeMsgpack)
+	return nil
+}
+
+// MsgpackUnmarshaller is an UnmarshalMethod decoding MessagePack.
+func MsgpackUnmarshaller(in []byte, out interface{}) error {
+	return msgpack.Unmarshal(in, out)
+}
+
+// CborMarshaller is a MarshalMethod encoding CBOR.
+func CborMarshaller(in interface{}, out *RpbContent) error {
+	data, err := cbor.Marshal(marshalValue(in))
+	if err != nil {
+		return err
+	}
+	out.Value = data
+	out.ContentType = []byte(ContentTypeCbor)
+	return nil
+}
+
+// CborUnmarshaller is an UnmarshalMethod decoding CBOR.
+func CborUnmarshaller(in []byte, out interface{}) error {
+	return cbor.Unmarshal(in, out)
+}
+
+// NewJsonCoder returns a Coder for structs with `json` tagged fields.
+func NewJsonCoder() *Coder {
+	return NewCoder("json", JsonMarshaller, JsonUnmarshaller)
+}
+
+// NewGobCoder returns a Coder encoding every struct with encoding/gob.
+func NewGobCoder() *Coder {
+	coder := NewCoder("", GobMarshaller, GobUnmarshaller)
+	coder.contentType = ContentTypeGob
+	return coder
+}
+
+// NewProtobufCoder returns a Coder for generated protocol buffer messages.
+func NewProtobufCoder() *Coder {
+	return NewCoder("protobuf", ProtobufMarshaller, ProtobufUnmarshaller)
+}
+
+// NewMsgpackCoder returns a Coder encoding every struct as MessagePack,
+// naming fields by their `msgpack` tags where set.
+func NewMsgpackCoder() *Coder {
+	coder := NewCoder("", MsgpackMarshaller, MsgpackUnmarshaller)
+	coder.contentType = ContentTypeMsgpack
+	return coder
+}
+
+// NewCborCoder returns a Coder encoding every struct as CBOR, naming fields
+// by their `cbor` tags where set.
+func NewCborCoder() *Coder {
+	coder := NewCoder("", CborMarshaller, CborUnmarshaller)
+	coder.contentType = ContentTypeCbor
+	return coder
+}
+
+// defaultCoders decode fetched content by its content type when neither the
+// client's registered coders nor its Coder match, see *Client.CoderFor().
+var defaultCoders = map[string]*Coder{
+	ContentTypeJson:     NewJsonCoder(),
+	ContentTypeGob:      NewGobCoder(),
+	ContentTypeProtobuf: NewProtobufCoder(),
+	ContentTypeMsgpack:  NewMsgpackCoder(),
+	ContentTypeCbor:     NewCborCoder(),
+}
+
+// RegisterCoder sets the Coder FetchStruct decodes content of contentType
+// with, see *Client.CoderFor().
+func (c *Client) RegisterCoder(contentType string, coder *Coder) {
+	if c.coders == nil {
+		c.coders = map[string]*Coder{}
+	}
+	c.coders[contentType] = coder
+}
+
+// CoderFor returns the Coder to decode content of contentType with. In order
+// of preference that is the Coder registered for the content type, the
+// client's Coder if it produces that content type or its content type is not
+// known, a built in Coder for the content type, and finally the client's
+// Coder regardless.
+func (c *Client) CoderFor(contentType string) *Coder {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	if coder, ok := c.coders[contentType]; ok {
+		return coder
+	}
+	if c.Coder != nil && (c.Coder.contentType == "" || c.Coder.contentType == contentType) {
+		return c.Coder
+	}
+	if coder, ok := defaultCoders[contentType]; ok {
+		return coder
+	}
+	return c.Coder
+}
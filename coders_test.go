@@ -0,0 +1,109 @@
+package riakpbc
+
+import (
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+type CodedData struct {
+	Email string `json:"email" msgpack:"email" cbor:"email" riak:"index"`
+	Count int    `json:"count" msgpack:"count" cbor:"count"`
+}
+
+func TestCoders(t *testing.T) {
+	coders := map[string]*Coder{
+		ContentTypeJson:    NewJsonCoder(),
+		ContentTypeGob:     NewGobCoder(),
+		ContentTypeMsgpack: NewMsgpackCoder(),
+		ContentTypeCbor:    NewCborCoder(),
+	}
+
+	for contentType, coder := range coders {
+		data := &CodedData{Email: "riak@example.com", Count: 3}
+		content, err := coder.Marshal(data)
+		if err != nil {
+			t.Fatalf("%s: %s", contentType, err)
+		}
+		if string(content.GetContentType()) != contentType {
+			t.Errorf("Expected %s, got %s", contentType, content.GetContentType())
+		}
+		if string(content.GetIndexes()[0].GetKey()) != "email_bin" {
+			t.Errorf("%s: expected the email_bin index", contentType)
+		}
+
+		result := &CodedData{}
+		if err := coder.UnmarshalContent(content, result); err != nil {
+			t.Fatalf("%s: %s", contentType, err)
+		}
+		if *result != *data {
+			t.Errorf("%s: expected %+v, got %+v", contentType, data, result)
+		}
+	}
+}
+
+func TestCodersUntagged(t *testing.T) {
+	type Untagged struct {
+		Email string
+		Count int
+	}
+
+	for _, coder := range []*Coder{NewGobCoder(), NewMsgpackCoder(), NewCborCoder()} {
+		data := &Untagged{Email: "riak@example.com", Count: 3}
+		content, err := coder.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content.GetValue()) == 0 {
+			t.Fatalf("%s: nothing marshalled", content.GetContentType())
+		}
+
+		result := &Untagged{}
+		if err := coder.UnmarshalContent(content, result); err != nil {
+			t.Fatalf("%s: %s", content.GetContentType(), err)
+		}
+		if *result != *data {
+			t.Errorf("%s: expected %+v, got %+v", content.GetContentType(), data, result)
+		}
+	}
+}
+
+func TestProtobufCoder(t *testing.T) {
+	coder := NewProtobufCoder()
+
+	data := &RpbLink{Bucket: []byte("bucket"), Key: []byte("key"), Tag: []byte("tag")}
+	content, err := coder.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content.GetContentType()) != ContentTypeProtobuf {
+		t.Errorf("Expected %s, got %s", ContentTypeProtobuf, content.GetContentType())
+	}
+
+	result := &RpbLink{}
+	if err := coder.Unmarshal(content.GetValue(), result); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(data, result) {
+		t.Errorf("Expected %v, got %v", data, result)
+	}
+}
+
+func TestCoderFor(t *testing.T) {
+	riak := NewClientWithCoder([]string{"127.0.0.1:8087"}, NewJsonCoder())
+
+	if riak.CoderFor("application/json; charset=utf-8") != riak.Coder {
+		t.Error("Expected the client coder for json")
+	}
+	if riak.CoderFor(ContentTypeMsgpack) != defaultCoders[ContentTypeMsgpack] {
+		t.Error("Expected the built in msgpack coder")
+	}
+	if riak.CoderFor("text/plain") != riak.Coder {
+		t.Error("Expected the client coder as a fallback")
+	}
+
+	gob := NewGobCoder()
+	riak.RegisterCoder(ContentTypeGob, gob)
+	if riak.CoderFor(ContentTypeGob) != gob {
+		t.Error("Expected the registered gob coder")
+	}
+}
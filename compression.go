@@ -0,0 +1,180 @@
+package riakpbc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sync"
+)
+
+// Content encodings of the built in compressors.
+const (
+	EncodingGzip   = "gzip"
+	EncodingSnappy = "snappy"
+	EncodingZstd   = "zstd"
+)
+
+// Compressor compresses object values stored by the client and names the
+// content encoding it produces, see *Client.SetCompression().
+type Compressor interface {
+	Encoding() string
+	Compress(in []byte) ([]byte, error)
+	Decompress(in []byte) ([]byte, error)
+}
+
+// GzipCompressor compresses values with gzip.
+type GzipCompressor struct {
+	Level int // gzip level, gzip.DefaultCompression if zero
+}
+
+func (g *GzipCompressor) Encoding() string {
+	return EncodingGzip
+}
+
+func (g *GzipCompressor) Compress(in []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(in); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *GzipCompressor) Decompress(in []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// SnappyCompressor compresses values with snappy.
+type SnappyCompressor struct{}
+
+func (s *SnappyCompressor) Encoding() string {
+	return EncodingSnappy
+}
+
+func (s *SnappyCompressor) Compress(in []byte) ([]byte, error) {
+	return snappy.Encode(nil, in), nil
+}
+
+func (s *SnappyCompressor) Decompress(in []byte) ([]byte, error) {
+	return snappy.Decode(nil, in)
+}
+
+// ZstdCompressor compresses values with zstd.
+type ZstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (z *ZstdCompressor) init() error {
+	z.once.Do(func() {
+		if z.encoder, z.err = zstd.NewWriter(nil); z.err != nil {
+			return
+		}
+		z.decoder, z.err = zstd.NewReader(nil)
+	})
+	return z.err
+}
+
+func (z *ZstdCompressor) Encoding() string {
+	return EncodingZstd
+}
+
+func (z *ZstdCompressor) Compress(in []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.encoder.EncodeAll(in, nil), nil
+}
+
+func (z *ZstdCompressor) Decompress(in []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.decoder.DecodeAll(in, nil)
+}
+
+// defaultCompressors decompress fetched values by their content encoding
+// when the client's Compressor doesn't produce that encoding.
+var defaultCompressors = map[string]Compressor{
+	EncodingGzip:   &GzipCompressor{},
+	EncodingSnappy: &SnappyCompressor{},
+	EncodingZstd:   &ZstdCompressor{},
+}
+
+// SetCompression compresses the values of objects and structs the client
+// stores with compressor, provided they are at least threshold bytes long and
+// carry no content encoding already. The content encoding is set to that of
+// the compressor. Pass a nil compressor to store values as they are.
+//
+// Fetched values are decompressed according to their content encoding
+// whether compression is set or not.
+func (c *Client) SetCompression(compressor Compressor, threshold int) {
+	c.compressor = compressor
+	c.compressThreshold = threshold
+}
+
+// compress returns content with its value compressed, if compression is set
+// and applies to it. The content passed in is left untouched.
+func (c *Client) compress(content *RpbContent) (*RpbContent, error) {
+	if c.compressor == nil || len(content.GetValue()) < c.compressThreshold || len(content.GetContentEncoding()) > 0 {
+		return content, nil
+	}
+
+	value, err := c.compressor.Compress(content.GetValue())
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := *content
+	compressed.Value = value
+	compressed.ContentEncoding = []byte(c.compressor.Encoding())
+	return &compressed, nil
+}
+
+// decompress decompresses every sibling of a fetched or stored object which
+// has a known content encoding, and clears the encoding so that the object
+// can be stored again as is.
+func (c *Client) decompress(contents []*RpbContent) error {
+	for _, content := range contents {
+		encoding := string(content.GetContentEncoding())
+		if encoding == "" || len(content.GetValue()) == 0 {
+			continue
+		}
+
+		compressor, ok := defaultCompressors[encoding]
+		if c.compressor != nil && c.compressor.Encoding() == encoding {
+			compressor, ok = c.compressor, true
+		}
+		if !ok {
+			continue
+		}
+
+		value, err := compressor.Decompress(content.GetValue())
+		if err != nil {
+			return err
+		}
+		content.Value = value
+		content.ContentEncoding = nil
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package riakpbc
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"sync"
+	"testing"
+)
+
+func TestCompressors(t *testing.T) {
+	value := bytes.Repeat([]byte("riak "), 100)
+
+	for _, compressor := range []Compressor{&GzipCompressor{}, &SnappyCompressor{}, &ZstdCompressor{}} {
+		compressed, err := compressor.Compress(value)
+		assert.T(t, err == nil)
+		assert.T(t, len(compressed) < len(value))
+
+		decompressed, err := compressor.Decompress(compressed)
+		assert.T(t, err == nil)
+		assert.Equal(t, value, decompressed)
+
+		_, err = compressor.Decompress([]byte("not compressed"))
+		assert.T(t, err != nil)
+	}
+}
+
+// newStoreServer starts a fake Riak node keeping a single stored object.
+func newStoreServer(t *testing.T) (string, func() *RpbContent) {
+	var lock sync.Mutex
+	var stored *RpbContent
+
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch structname {
+		case "RpbPutReq":
+			req := &RpbPutReq{}
+			proto.Unmarshal(body, req)
+			stored = req.GetContent()
+			if req.GetReturnBody() {
+				return "RpbPutResp", &RpbPutResp{Content: []*RpbContent{stored}}
+			}
+			return "RpbPutResp", nil
+		case "RpbGetReq":
+			return "RpbGetResp", &RpbGetResp{Content: []*RpbContent{stored}}
+		}
+		return "RpbPingResp", nil
+	})
+
+	return addr, func() *RpbContent {
+		lock.Lock()
+		defer lock.Unlock()
+		return stored
+	}
+}
+
+func TestCompression(t *testing.T) {
+	addr, stored := newStoreServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.SetCompression(&SnappyCompressor{}, 64)
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	value := bytes.Repeat([]byte("riak "), 100)
+	content := &RpbContent{Value: value, ContentType: []byte("text/plain")}
+	_, err := riak.StoreObject("bucket", "key", content)
+	assert.T(t, err == nil)
+
+	assert.Equal(t, "snappy", string(stored().GetContentEncoding()))
+	assert.T(t, len(stored().GetValue()) < len(value))
+	assert.Equal(t, "text/plain", string(stored().GetContentType()))
+
+	// The caller's content is left as it was
+	assert.Equal(t, value, content.GetValue())
+	assert.T(t, content.ContentEncoding == nil)
+
+	obj, err := riak.FetchObject("bucket", "key")
+	assert.T(t, err == nil)
+	assert.Equal(t, value, obj.GetContent()[0].GetValue())
+	assert.T(t, obj.GetContent()[0].ContentEncoding == nil)
+
+	// A returned body is decompressed like a fetched one
+	resp, err := riak.StoreObject("bucket", "key", content, &PutOptions{ReturnBody: true})
+	assert.T(t, err == nil)
+	assert.Equal(t, "snappy", string(stored().GetContentEncoding()))
+	assert.Equal(t, value, resp.GetContent()[0].GetValue())
+	assert.T(t, resp.GetContent()[0].ContentEncoding == nil)
+
+	// Values below the threshold are stored as they are
+	_, err = riak.StoreObject("bucket", "key", "small")
+	assert.T(t, err == nil)
+	assert.Equal(t, "small", string(stored().GetValue()))
+	assert.T(t, stored().ContentEncoding == nil)
+}
+
+func TestCompressionStruct(t *testing.T) {
+	addr, stored := newStoreServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	riak.SetCompression(&GzipCompressor{}, 0)
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	type Data struct {
+		Data string `json:"data"`
+	}
+
+	_, err := riak.StoreStruct("bucket", "key", &Data{Data: "compressed"})
+	assert.T(t, err == nil)
+	assert.Equal(t, "gzip", string(stored().GetContentEncoding()))
+
+	resp, err := riak.StoreStruct("bucket", "key", &Data{Data: "returned"}, &PutOptions{ReturnBody: true})
+	assert.T(t, err == nil)
+	assert.Equal(t, `{"data":"returned"}`, string(resp.GetContent()[0].GetValue()))
+	assert.T(t, resp.GetContent()[0].ContentEncoding == nil)
+
+	_, err = riak.StoreStruct("bucket", "key", &Data{Data: "compressed"})
+	assert.T(t, err == nil)
+
+	// Known encodings are decompressed even with compression turned off
+	riak.SetCompression(nil, 0)
+
+	out := &Data{}
+	_, err = riak.FetchStruct("bucket", "key", out)
+	assert.T(t, err == nil)
+	assert.Equal(t, "compressed", out.Data)
+}
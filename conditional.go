@@ -0,0 +1,55 @@
+package riakpbc
+
+import (
+	"errors"
+)
+
+// putError translates the errors Riak returns for failed conditional puts
+// into ErrAlreadyExists, ErrModified and ErrObjectNotFound.
+func putError(err error) error {
+	var riakErr *RiakError
+	if !errors.As(err, &riakErr) {
+		return err
+	}
+	switch riakErr.Message {
+	case "match_found":
+		return ErrAlreadyExists
+	case "modified":
+		return ErrModified
+	case "notfound":
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+// Insert stores in under key only if the key doesn't exist yet, and fails
+// with ErrAlreadyExists otherwise.
+//
+// in is either a struct, encoded with the client's Coder, or any content
+// StoreObject accepts.
+func (c *Client) Insert(bucket, key string, in interface{}, opts ...*PutOptions) (*RpbPutResp, error) {
+	req := c.putRequest(bucket, key, opts)
+	req.IfNoneMatch = flag(true)
+	return c.storeStruct("Insert", req, bucket, key, in)
+}
+
+// CompareAndSwap replaces the object stored under key with in only if vclock
+// is still its current vclock, and fails with ErrModified if it was changed
+// since, or ErrObjectNotFound if it no longer exists.
+//
+// in is either a struct, encoded with the client's Coder, or any content
+// StoreObject accepts. A nil vclock is taken from the `riak:"vclock"` field
+// of a struct.
+func (c *Client) CompareAndSwap(bucket, key string, vclock []byte, in interface{}, opts ...*PutOptions) (*RpbPutResp, error) {
+	if vclock == nil {
+		vclock = structVclock(in)
+	}
+	if len(vclock) == 0 {
+		return nil, errors.New("CompareAndSwap requires a vclock")
+	}
+
+	req := c.putRequest(bucket, key, opts)
+	req.Vclock = vclock
+	req.IfNotModified = flag(true)
+	return c.storeStruct("CompareAndSwap", req, bucket, key, in)
+}
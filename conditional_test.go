@@ -0,0 +1,91 @@
+package riakpbc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+func TestPutError(t *testing.T) {
+	assert.Equal(t, ErrAlreadyExists, putError(&RiakError{Message: "match_found"}))
+	assert.Equal(t, ErrModified, putError(&RiakError{Message: "modified"}))
+	assert.Equal(t, ErrObjectNotFound, putError(&RiakError{Message: "notfound"}))
+	assert.Equal(t, "0: overload", putError(&RiakError{Message: "overload"}).Error())
+	assert.Equal(t, ErrModified, putError(fmt.Errorf("storing: %w", &RiakError{Message: "modified"})))
+
+	// Only Riak's own error responses are translated
+	assert.Equal(t, "read: modified", putError(errors.New("read: modified")).Error())
+	assert.Equal(t, "0: not modified", putError(&RiakError{Message: "not modified"}).Error())
+}
+
+func TestInsert(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	_, err := riak.Insert("bucket", "raw", []byte("first"))
+	assert.T(t, err == nil)
+	_, err = riak.Insert("bucket", "raw", []byte("second"))
+	assert.Equal(t, ErrAlreadyExists, err)
+
+	obj, err := riak.FetchObject("bucket", "raw")
+	assert.T(t, err == nil)
+	assert.Equal(t, "first", string(obj.GetContent()[0].GetValue()))
+
+	_, err = riak.Insert("bucket", "struct", &Data{Data: "first"})
+	assert.T(t, err == nil)
+	_, err = riak.Insert("bucket", "struct", &Data{Data: "second"})
+	assert.Equal(t, ErrAlreadyExists, err)
+
+	_, err = riak.Insert("bucket", "content", &RpbContent{Value: []byte("content")})
+	assert.T(t, err == nil)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	_, err := riak.CompareAndSwap("bucket", "key", []byte("vclock"), "value")
+	assert.Equal(t, ErrObjectNotFound, err)
+	_, err = riak.CompareAndSwap("bucket", "key", nil, "value")
+	assert.T(t, err != nil)
+
+	resp, err := riak.StoreObject("bucket", "key", "first")
+	assert.T(t, err == nil)
+	vclock := resp.GetVclock()
+
+	_, err = riak.CompareAndSwap("bucket", "key", vclock, "second")
+	assert.T(t, err == nil)
+	_, err = riak.CompareAndSwap("bucket", "key", vclock, "third")
+	assert.Equal(t, ErrModified, err)
+
+	obj, err := riak.FetchObject("bucket", "key")
+	assert.T(t, err == nil)
+	assert.Equal(t, "second", string(obj.GetContent()[0].GetValue()))
+
+	// Structs carry their own vclock
+	type Versioned struct {
+		Vclock []byte `json:"-" riak:"vclock"`
+		Data   string `json:"data"`
+	}
+
+	_, err = riak.StoreStruct("bucket", "struct", &Versioned{Data: "first"})
+	assert.T(t, err == nil)
+	v := &Versioned{}
+	_, err = riak.FetchStruct("bucket", "struct", v)
+	assert.T(t, err == nil)
+
+	v.Data = "second"
+	_, err = riak.CompareAndSwap("bucket", "struct", nil, v)
+	assert.T(t, err == nil)
+	_, err = riak.CompareAndSwap("bucket", "struct", nil, v)
+	assert.Equal(t, ErrModified, err)
+}
@@ -2,6 +2,7 @@ package riakpbc
 
 import (
 	"errors"
+	"strconv"
 )
 
 var (
@@ -17,4 +18,20 @@ var (
 	ErrZeroNodes      = errors.New("zero nodes in pool")
 	ErrNoContent      = errors.New("no content")
 	ErrAllNodesDown   = errors.New("all nodes down")
+	ErrNoCoder        = errors.New("no coder set")
+	ErrAlreadyExists  = errors.New("already exists")
+	ErrModified       = errors.New("modified")
+	ErrCanceled       = errors.New("canceled")
+	ErrListingRefused = errors.New("listing refused, see AllowListing")
 )
+
+// RiakError is an error response sent by Riak, as opposed to an error sending
+// the request or reading the response.
+type RiakError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *RiakError) Error() string {
+	return strconv.Itoa(int(e.Code)) + ": " + e.Message
+}
@@ -0,0 +1,147 @@
+package riakpbc
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Awaitable is the part of a Future which doesn't depend on its value, for
+// WaitAll and WaitAny to mix futures of different types.
+type Awaitable interface {
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Future is the pending result of a request made in the background.
+type Future[T any] struct {
+	done  chan struct{}
+	once  sync.Once
+	value T
+	err   error
+}
+
+// Async calls fn in the background, returning a Future of its result. Any
+// client call can be made asynchronous this way:
+//
+//	future := riakpbc.Async(func() (*riakpbc.RpbIndexResp, error) {
+//		return client.Index("bucket", "email_bin", "bob@example.com", "", "")
+//	})
+func Async[T any](fn func() (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	go func() {
+		value, err := fn()
+		f.resolve(value, err)
+	}()
+	return f
+}
+
+// resolve completes the future, unless it was completed already.
+func (f *Future[T]) resolve(value T, err error) {
+	f.once.Do(func() {
+		f.value = value
+		f.err = err
+		close(f.done)
+	})
+}
+
+// Wait blocks until the request completes or the future is canceled, and
+// returns its result.
+func (f *Future[T]) Wait() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Done returns a channel closed once Wait would no longer block.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the error of a completed future, or nil while it is pending.
+func (f *Future[T]) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Cancel completes a pending future with ErrCanceled. The request itself
+// still runs to completion on its node, but its result is dropped.
+func (f *Future[T]) Cancel() {
+	var zero T
+	f.resolve(zero, ErrCanceled)
+}
+
+// WaitAll blocks until every future completes, and returns the first error
+// among them in the order given, if any.
+func WaitAll(futures ...Awaitable) error {
+	for _, f := range futures {
+		<-f.Done()
+	}
+	for _, f := range futures {
+		if err := f.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitAny blocks until one of the futures completes, and returns its
+// position, or -1 when there are none.
+func WaitAny(futures ...Awaitable) int {
+	if len(futures) == 0 {
+		return -1
+	}
+
+	cases := make([]reflect.SelectCase, len(futures))
+	for i, f := range futures {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.Done())}
+	}
+	chosen, _, _ := reflect.Select(cases)
+	return chosen
+}
+
+// FetchObjectAsync is FetchObject made in the background.
+func (c *Client) FetchObjectAsync(bucket, key string, opts ...*GetOptions) *Future[*RpbGetResp] {
+	return Async(func() (*RpbGetResp, error) {
+		return c.FetchObject(bucket, key, opts...)
+	})
+}
+
+// StoreObjectAsync is StoreObject made in the background.
+func (c *Client) StoreObjectAsync(bucket, key string, in interface{}, opts ...*PutOptions) *Future[*RpbPutResp] {
+	return Async(func() (*RpbPutResp, error) {
+		return c.StoreObject(bucket, key, in, opts...)
+	})
+}
+
+// DeleteObjectAsync is DeleteObject made in the background.
+func (c *Client) DeleteObjectAsync(bucket, key string, opts ...*DeleteOptions) *Future[[]byte] {
+	return Async(func() ([]byte, error) {
+		return c.DeleteObject(bucket, key, opts...)
+	})
+}
+
+// FetchStructAsync is FetchStruct made in the background. out must not be
+// used until the future completes; a canceled request may still write it.
+func (c *Client) FetchStructAsync(bucket, key string, out interface{}, opts ...*GetOptions) *Future[*RpbGetResp] {
+	return Async(func() (*RpbGetResp, error) {
+		return c.FetchStruct(bucket, key, out, opts...)
+	})
+}
+
+// StoreStructAsync is StoreStruct made in the background. in must not be
+// modified until the future completes.
+func (c *Client) StoreStructAsync(bucket, key string, in interface{}, opts ...*PutOptions) *Future[*RpbPutResp] {
+	return Async(func() (*RpbPutResp, error) {
+		return c.StoreStruct(bucket, key, in, opts...)
+	})
+}
+
+// FetchMetaAsync is FetchMeta made in the background.
+func (c *Client) FetchMetaAsync(bucket, key string, opts ...*GetOptions) *Future[*Meta] {
+	return Async(func() (*Meta, error) {
+		return c.FetchMeta(bucket, key, opts...)
+	})
+}
@@ -0,0 +1,99 @@
+package riakpbc
+
+import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestFuture(t *testing.T) {
+	release := make(chan struct{})
+	f := Async(func() (int, error) {
+		<-release
+		return 42, nil
+	})
+
+	select {
+	case <-f.Done():
+		t.Fatal("future completed early")
+	default:
+	}
+	assert.T(t, f.Err() == nil)
+
+	close(release)
+	value, err := f.Wait()
+	assert.T(t, err == nil)
+	assert.Equal(t, 42, value)
+
+	// Canceling a completed future changes nothing
+	f.Cancel()
+	value, err = f.Wait()
+	assert.T(t, err == nil)
+	assert.Equal(t, 42, value)
+}
+
+func TestFutureCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	f := Async(func() (string, error) {
+		<-release
+		return "late", nil
+	})
+	f.Cancel()
+
+	value, err := f.Wait()
+	assert.Equal(t, ErrCanceled, err)
+	assert.Equal(t, "", value)
+	assert.Equal(t, ErrCanceled, f.Err())
+}
+
+func TestWaitAllAndAny(t *testing.T) {
+	failure := errors.New("failure")
+
+	slow := Async(func() (int, error) {
+		time.Sleep(20 * time.Millisecond)
+		return 1, nil
+	})
+	fast := Async(func() (string, error) {
+		return "", failure
+	})
+
+	assert.Equal(t, 1, WaitAny(slow, fast))
+	assert.Equal(t, failure, WaitAll(slow, fast))
+	assert.T(t, WaitAll(slow) == nil)
+	assert.Equal(t, -1, WaitAny())
+}
+
+func TestAsyncRequests(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	stores := []Awaitable{
+		riak.StoreObjectAsync("bucket", "object", "value"),
+		riak.StoreStructAsync("bucket", "struct", &Data{Data: "data"}),
+	}
+	assert.T(t, WaitAll(stores...) == nil)
+
+	out := &Data{}
+	object := riak.FetchObjectAsync("bucket", "object")
+	structure := riak.FetchStructAsync("bucket", "struct", out)
+	meta := riak.FetchMetaAsync("bucket", "object")
+	assert.T(t, WaitAll(object, structure, meta) == nil)
+
+	obj, _ := object.Wait()
+	assert.Equal(t, "value", string(obj.GetContent()[0].GetValue()))
+	assert.Equal(t, "data", out.Data)
+	m, _ := meta.Wait()
+	assert.Equal(t, "plain/text", m.ContentType)
+
+	_, err := riak.DeleteObjectAsync("bucket", "object").Wait()
+	assert.T(t, err == nil)
+	_, err = riak.FetchObjectAsync("bucket", "object").Wait()
+	assert.Equal(t, ErrObjectNotFound, err)
+}
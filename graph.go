@@ -0,0 +1,221 @@
+package riakpbc
+
+import (
+	"net/url"
+	"sort"
+)
+
+// LinkIndex is the secondary index link updates maintain when link indexing
+// is enabled, holding a LinkIndexValue per link of an object so that the
+// objects linking to another can be found, see *Client.LinkedFrom().
+const LinkIndex = "$link_bin"
+
+// LinkIndexValue is the LinkIndex value of a link to bucket/key with tag.
+func LinkIndexValue(bucket, key, tag string) string {
+	return url.PathEscape(bucket) + "/" + url.PathEscape(key) + "/" + url.PathEscape(tag)
+}
+
+// SetLinkIndexing makes LinkAdd, LinkRemove, SetLinks and UpdateLinks keep the
+// LinkIndex of the objects they update in step with their links.
+func (c *Client) SetLinkIndexing(enabled bool) {
+	c.linkIndexing = enabled
+}
+
+// indexLinks replaces the LinkIndex values of content with those of its
+// links.
+func indexLinks(content *RpbContent) {
+	indexes := make([]*RpbPair, 0, len(content.GetIndexes())+len(content.GetLinks()))
+	for _, index := range content.GetIndexes() {
+		if string(index.GetKey()) != LinkIndex {
+			indexes = append(indexes, index)
+		}
+	}
+	for _, l := range content.GetLinks() {
+		indexes = append(indexes, &RpbPair{
+			Key:   []byte(LinkIndex),
+			Value: []byte(LinkIndexValue(string(l.GetBucket()), string(l.GetKey()), string(l.GetTag()))),
+		})
+	}
+	content.Indexes = indexes
+}
+
+// LinkedFrom returns the keys of the objects in bucket which link to
+// lbucket/lkey with ltag, or with any tag if ltag is empty. Only links stored
+// with link indexing enabled are found.
+func (c *Client) LinkedFrom(bucket, lbucket, lkey, ltag string) (keys []string, err error) {
+	c, span := c.startOperation("LinkedFrom")
+	span.SetAttribute(AttrBucket, bucket)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	var resp *RpbIndexResp
+	if ltag != "" {
+		resp, err = c.Index(bucket, LinkIndex, LinkIndexValue(lbucket, lkey, ltag), "", "")
+	} else {
+		// Escaped tags sort below \x7f
+		prefix := url.PathEscape(lbucket) + "/" + url.PathEscape(lkey) + "/"
+		resp, err = c.Index(bucket, LinkIndex, "", prefix, prefix+"\x7f")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// An object linking with several tags is listed once per link
+	keys = stringKeys(resp.GetKeys())
+	sort.Strings(keys)
+	unique := keys[:0]
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		unique = append(unique, key)
+	}
+	return unique, nil
+}
+
+// TraverseOptions are the optional parameters of BFS and DFS.
+type TraverseOptions struct {
+	Bucket      string // follow only links to this bucket, any if empty
+	Tag         string // follow only links with this tag, any if empty
+	MaxDepth    int    // links followed from the start at most, unlimited if zero
+	Concurrency int    // fetches in flight at once, DefaultBatchConcurrency if zero
+}
+
+func (opts *TraverseOptions) concurrency() int {
+	if opts == nil || opts.Concurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return opts.Concurrency
+}
+
+// follows reports whether the links of an object at depth are followed.
+func (opts *TraverseOptions) follows(depth int) bool {
+	return opts == nil || opts.MaxDepth <= 0 || depth < opts.MaxDepth
+}
+
+// links returns the objects the links of objs point to, as the options
+// filter them.
+func (opts *TraverseOptions) links(objs []*WalkObject) []*WalkObject {
+	if opts == nil {
+		return linkedObjects(objs, "", "")
+	}
+	return linkedObjects(objs, opts.Bucket, opts.Tag)
+}
+
+// Neighbors returns the objects bucket/key links to with tag, or with any tag
+// if tag is empty. Links to objects which don't exist are left out.
+func (c *Client) Neighbors(bucket, key, tag string) (neighbors []*WalkObject, err error) {
+	c, span := c.startOperation("Neighbors")
+	span.SetAttribute(AttrBucket, bucket)
+	span.SetAttribute(AttrKey, key)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	start, err := c.FetchObject(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	current := []*WalkObject{{Bucket: bucket, Key: key, Object: start}}
+	return c.fetchWalkObjects(linkedObjects(current, "", tag), DefaultBatchConcurrency)
+}
+
+// BFS traverses the links of bucket/key breadth first, returning the objects
+// reached in the order they are, the start first. Every object is returned
+// once, at the depth it is first reached at, so cycles end the traversal.
+// The objects at each depth are fetched concurrently, and links to objects
+// which don't exist are left out.
+func (c *Client) BFS(bucket, key string, opts *TraverseOptions) (reached []*WalkObject, err error) {
+	c, span := c.startOperation("BFS")
+	span.SetAttribute(AttrBucket, bucket)
+	span.SetAttribute(AttrKey, key)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	start, err := c.FetchObject(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	current := []*WalkObject{{Bucket: bucket, Key: key, Object: start}}
+	visited := map[string]bool{bucket + "/" + key: true}
+	reached = current
+
+	for depth := 0; len(current) > 0 && opts.follows(depth); depth++ {
+		linked := opts.links(current)
+		next := linked[:0]
+		for _, obj := range linked {
+			if !visited[obj.Bucket+"/"+obj.Key] {
+				visited[obj.Bucket+"/"+obj.Key] = true
+				next = append(next, obj)
+			}
+		}
+
+		current, err = c.fetchWalkObjects(next, opts.concurrency())
+		if err != nil {
+			return nil, err
+		}
+		reached = append(reached, current...)
+	}
+
+	return reached, nil
+}
+
+// DFS traverses the links of bucket/key depth first, returning the objects
+// reached in the order they are, the start first. Every object is returned
+// once, so cycles end the traversal. The objects an object links to are
+// fetched concurrently before descending into the first of them, and links
+// to objects which don't exist are left out.
+func (c *Client) DFS(bucket, key string, opts *TraverseOptions) (reached []*WalkObject, err error) {
+	c, span := c.startOperation("DFS")
+	span.SetAttribute(AttrBucket, bucket)
+	span.SetAttribute(AttrKey, key)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	start, err := c.FetchObject(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := map[string]bool{}
+
+	var visit func(obj *WalkObject) error
+	visit = func(obj *WalkObject) error {
+		if visited[obj.Bucket+"/"+obj.Key] {
+			return nil
+		}
+		visited[obj.Bucket+"/"+obj.Key] = true
+		reached = append(reached, obj)
+		if !opts.follows(obj.Depth) {
+			return nil
+		}
+
+		var next []*WalkObject
+		for _, linked := range opts.links([]*WalkObject{obj}) {
+			if !visited[linked.Bucket+"/"+linked.Key] {
+				next = append(next, linked)
+			}
+		}
+
+		next, err := c.fetchWalkObjects(next, opts.concurrency())
+		if err != nil {
+			return err
+		}
+		for _, linked := range next {
+			if err := visit(linked); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(&WalkObject{Bucket: bucket, Key: key, Object: start}); err != nil {
+		return nil, err
+	}
+	return reached, nil
+}
@@ -0,0 +1,130 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+)
+
+// depthKeys returns the keys of objects reached by a traversal, with their
+// depth.
+func depthKeys(objs []*WalkObject) []string {
+	keys := make([]string, len(objs))
+	for i, obj := range objs {
+		keys[i] = obj.Key + ":" + string(rune('0'+obj.Depth))
+	}
+	return keys
+}
+
+func setupGraph(t *testing.T) (*memServer, *Client) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	riak.SetLinkIndexing(true)
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		riak.StoreObject("people", key, key)
+	}
+	assert.T(t, riak.LinkAdd("people", "a", "people", "b", "friend") == nil)
+	assert.T(t, riak.LinkAdd("people", "a", "people", "c", "friend") == nil)
+	assert.T(t, riak.LinkAdd("people", "b", "people", "d", "friend") == nil)
+	assert.T(t, riak.LinkAdd("people", "b", "people", "missing", "friend") == nil)
+	assert.T(t, riak.LinkAdd("people", "c", "people", "a", "friend") == nil)
+	assert.T(t, riak.LinkAdd("people", "c", "people", "d", "colleague") == nil)
+	assert.T(t, riak.LinkAdd("people", "d", "people", "e", "colleague") == nil)
+
+	return server, riak
+}
+
+func TestNeighbors(t *testing.T) {
+	_, riak := setupGraph(t)
+	defer riak.Close()
+
+	neighbors, err := riak.Neighbors("people", "c", "")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:1", "d:1"}, depthKeys(neighbors))
+	assert.Equal(t, "a", string(neighbors[0].Object.GetContent()[0].GetValue()))
+
+	neighbors, err = riak.Neighbors("people", "c", "colleague")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"d:1"}, depthKeys(neighbors))
+	assert.Equal(t, "colleague", neighbors[0].Tag)
+
+	// Links to objects which don't exist are left out
+	neighbors, err = riak.Neighbors("people", "b", "")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"d:1"}, depthKeys(neighbors))
+
+	_, err = riak.Neighbors("people", "nobody", "")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestBFS(t *testing.T) {
+	_, riak := setupGraph(t)
+	defer riak.Close()
+
+	reached, err := riak.BFS("people", "a", nil)
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:0", "b:1", "c:1", "d:2", "e:3"}, depthKeys(reached))
+
+	reached, err = riak.BFS("people", "a", &TraverseOptions{MaxDepth: 2, Concurrency: 1})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:0", "b:1", "c:1", "d:2"}, depthKeys(reached))
+
+	reached, err = riak.BFS("people", "a", &TraverseOptions{Tag: "friend"})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:0", "b:1", "c:1", "d:2"}, depthKeys(reached))
+
+	reached, err = riak.BFS("people", "a", &TraverseOptions{Bucket: "pets"})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:0"}, depthKeys(reached))
+}
+
+func TestDFS(t *testing.T) {
+	_, riak := setupGraph(t)
+	defer riak.Close()
+
+	reached, err := riak.DFS("people", "a", nil)
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"a:0", "b:1", "d:2", "e:3", "c:1"}, depthKeys(reached))
+
+	reached, err = riak.DFS("people", "c", &TraverseOptions{MaxDepth: 1})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"c:0", "a:1", "d:1"}, depthKeys(reached))
+
+	reached, err = riak.DFS("people", "c", &TraverseOptions{Tag: "friend"})
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"c:0", "a:1", "b:2", "d:3"}, depthKeys(reached))
+}
+
+func TestLinkedFrom(t *testing.T) {
+	server, riak := setupGraph(t)
+	defer riak.Close()
+
+	keys, err := riak.LinkedFrom("people", "people", "d", "")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"b", "c"}, keys)
+
+	keys, err = riak.LinkedFrom("people", "people", "d", "colleague")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"c"}, keys)
+
+	keys, err = riak.LinkedFrom("people", "people", "dd", "")
+	assert.T(t, err == nil)
+	assert.Equal(t, 0, len(keys))
+
+	// Removing a link drops it from the index, keeping other indexes
+	riak.StoreObject("people", "f", &RpbContent{
+		Value:   []byte("f"),
+		Indexes: []*RpbPair{{Key: []byte("name_bin"), Value: []byte("f")}},
+	})
+	assert.T(t, riak.LinkAdd("people", "f", "people", "d", "friend") == nil)
+	assert.T(t, riak.LinkRemove("people", "f", "people", "d") == nil)
+	keys, _ = riak.LinkedFrom("people", "people", "d", "")
+	assert.Equal(t, []string{"b", "c"}, keys)
+	indexes := server.object("people", "f").content[0].GetIndexes()
+	assert.Equal(t, 1, len(indexes))
+	assert.Equal(t, "name_bin", string(indexes[0].GetKey()))
+
+	assert.Equal(t, "a%2Fb/c/friend", LinkIndexValue("a/b", "c", "friend"))
+}
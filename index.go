@@ -0,0 +1,193 @@
+package riakpbc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Index type suffixes, as returned by IndexEncoder.
+const (
+	IndexBin = "_bin"
+	IndexInt = "_int"
+)
+
+// DefaultIndexPrecision is the number of decimal places floats keep in an
+// index unless the tag sets a precision, see Coder.Marshal().
+const DefaultIndexPrecision = 6
+
+// IndexEncoder is implemented by types that encode themselves as a secondary
+// index value. suffix is IndexBin or IndexInt.
+type IndexEncoder interface {
+	EncodeIndex() (suffix string, value []byte, err error)
+}
+
+var typeOfTime = reflect.TypeOf(time.Time{})
+var typeOfIndexEncoder = reflect.TypeOf((*IndexEncoder)(nil)).Elem()
+
+// indexEncoder returns v as an IndexEncoder, if it or its address is one.
+func indexEncoder(v reflect.Value) (IndexEncoder, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type().Implements(typeOfIndexEncoder) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(IndexEncoder), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(typeOfIndexEncoder) {
+		return v.Addr().Interface().(IndexEncoder), true
+	}
+	return nil, false
+}
+
+// indexTime encodes a time.Time according to the `time` tag option: unix
+// (seconds, the default) and millis as _int, rfc3339 as _bin.
+func indexTime(t time.Time, directive *riakTag) (string, []byte, error) {
+	switch format := directive.options["time"]; format {
+	case "", "unix":
+		return IndexInt, []byte(strconv.FormatInt(t.Unix(), 10)), nil
+	case "millis":
+		return IndexInt, []byte(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)), nil
+	case "rfc3339":
+		return IndexBin, []byte(t.UTC().Format(time.RFC3339)), nil
+	default:
+		return "", nil, errors.New(fmt.Sprintf("Unknown index time format %q", format))
+	}
+}
+
+// indexFloat encodes a float as an _int of the value scaled by 10^precision,
+// so that range queries keep numeric order.
+func indexFloat(f float64, directive *riakTag) (string, []byte, error) {
+	precision := DefaultIndexPrecision
+	if p, ok := directive.options["precision"]; ok {
+		var err error
+		if precision, err = strconv.Atoi(p); err != nil || precision < 0 {
+			return "", nil, errors.New(fmt.Sprintf("Invalid index precision %q", p))
+		}
+	}
+
+	scaled := math.Round(f * math.Pow10(precision))
+	if math.IsNaN(scaled) || math.IsInf(scaled, 0) || math.Abs(scaled) >= 1<<63 {
+		return "", nil, errors.New(fmt.Sprintf("Cannot index float %v with precision %d", f, precision))
+	}
+	return IndexInt, []byte(strconv.FormatInt(int64(scaled), 10)), nil
+}
+
+// indexValue encodes a single value for a secondary index, along with the
+// suffix of the index type it belongs to.
+func indexValue(v reflect.Value, directive *riakTag) (suffix string, value []byte, err error) {
+	if encoder, ok := indexEncoder(v); ok {
+		return encoder.EncodeIndex()
+	}
+
+	if v.Type() == typeOfTime && v.CanInterface() {
+		return indexTime(v.Interface().(time.Time), directive)
+	}
+	if isBytes(v.Type()) {
+		return IndexBin, v.Bytes(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IndexInt, []byte(strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IndexInt, []byte(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return indexFloat(v.Float(), directive)
+	case reflect.Bool:
+		if v.Bool() {
+			return IndexInt, []byte("1"), nil
+		}
+		return IndexInt, []byte("0"), nil
+	case reflect.String:
+		return IndexBin, []byte(v.String()), nil
+	}
+
+	return "", nil, errors.New(fmt.Sprintf("Cannot index a %s", v.Type()))
+}
+
+// indexName names the index of a field, either explicitly through the tag
+// value or after the lowercased field name, behind any prefix set by the
+// structs the field is nested in.
+func indexName(fld reflect.StructField, directive *riakTag, prefix, suffix string) string {
+	if name := directive.value; name != "" {
+		if strings.HasSuffix(name, IndexBin) || strings.HasSuffix(name, IndexInt) {
+			return prefix + name
+		}
+		return prefix + name + suffix
+	}
+	return prefix + strings.ToLower(fld.Name+suffix)
+}
+
+// marshalIndex builds the index entries of an `index` tagged field.
+func marshalIndex(fld reflect.StructField, v reflect.Value, directive *riakTag, prefix string) ([]*RpbPair, error) {
+	if v.Kind() == reflect.Ptr {
+		if _, ok := indexEncoder(v); !ok {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return marshalIndex(fld, v.Elem(), directive, prefix)
+		}
+	}
+
+	var elems []reflect.Value
+
+	_, isEncoder := indexEncoder(v)
+	switch {
+	case isEncoder, isBytes(v.Type()):
+		elems = []reflect.Value{v}
+	case v.Kind() == reflect.Slice, v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, v.Index(i))
+		}
+	case v.Kind() == reflect.Map:
+		elems = v.MapKeys()
+	default:
+		elems = []reflect.Value{v}
+	}
+
+	var indexes []*RpbPair
+	for _, elem := range elems {
+		suffix, value, err := indexValue(elem, directive)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Field %s: %s", fld.Name, err))
+		}
+		indexes = append(indexes, &RpbPair{
+			Key:   []byte(indexName(fld, directive, prefix, suffix)),
+			Value: value,
+		})
+	}
+
+	// Map keys come in random order
+	if v.Kind() == reflect.Map {
+		sort.Slice(indexes, func(i, j int) bool {
+			return string(indexes[i].Value) < string(indexes[j].Value)
+		})
+	}
+
+	return indexes, nil
+}
+
+// uniqueIndexes drops repeated index entries, keeping the first of each.
+func uniqueIndexes(indexes []*RpbPair) []*RpbPair {
+	seen := make(map[string]bool, len(indexes))
+	unique := indexes[:0]
+
+	for _, index := range indexes {
+		entry := string(index.GetKey()) + "\x00" + string(index.GetValue())
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		unique = append(unique, index)
+	}
+
+	return unique
+}
@@ -1,59 +1,196 @@
 package riakpbc
 
-// LinkAdd sets a link reference to the link bucket/key in bucket/key.
-//
-// Note that this can be manually done by passing RpbContent to StoreObject.
-func (c *Client) LinkAdd(bucket, key, lbucket, lkey, ltag string) error {
-	obj, err := c.FetchObject(bucket, key)
-	if err != nil {
-		return err
+// LinkRetries is the number of times a link update is retried when the
+// object is modified between fetching and storing it.
+const LinkRetries = 3
+
+// SiblingResolver picks the sibling whose value and metadata are kept when a
+// link update stores an object with siblings back as a single value.
+type SiblingResolver func(siblings []*RpbContent) *RpbContent
+
+// LatestSibling is the default SiblingResolver, picking the most recently
+// modified sibling which isn't deleted.
+func LatestSibling(siblings []*RpbContent) *RpbContent {
+	var latest *RpbContent
+	for _, sibling := range siblings {
+		if sibling.GetDeleted() {
+			continue
+		}
+		if latest == nil || sibling.GetLastMod() > latest.GetLastMod() ||
+			sibling.GetLastMod() == latest.GetLastMod() && sibling.GetLastModUsecs() > latest.GetLastModUsecs() {
+			latest = sibling
+		}
+	}
+	return latest
+}
+
+// SetSiblingResolver sets how link updates resolve siblings, LatestSibling
+// unless set.
+func (c *Client) SetSiblingResolver(resolver SiblingResolver) {
+	c.siblingResolver = resolver
+}
+
+// resolveSiblings picks the content a link update stores back.
+func (c *Client) resolveSiblings(siblings []*RpbContent) *RpbContent {
+	if c.siblingResolver != nil {
+		return c.siblingResolver(siblings)
+	}
+	return LatestSibling(siblings)
+}
+
+// sameLink reports whether two links point to the same object with the same
+// tag.
+func sameLink(a, b *RpbLink) bool {
+	return string(a.GetBucket()) == string(b.GetBucket()) &&
+		string(a.GetKey()) == string(b.GetKey()) &&
+		string(a.GetTag()) == string(b.GetTag())
+}
+
+// uniqueLinks drops repeated links, keeping the first of each.
+func uniqueLinks(links []*RpbLink) []*RpbLink {
+	unique := make([]*RpbLink, 0, len(links))
+	for _, l := range links {
+		repeated := false
+		for _, u := range unique {
+			if sameLink(l, u) {
+				repeated = true
+				break
+			}
+		}
+		if !repeated {
+			unique = append(unique, l)
+		}
+	}
+	return unique
+}
+
+// sameLinks reports whether two sets of links are equal, in any order.
+func sameLinks(a, b []*RpbLink) bool {
+	a, b = uniqueLinks(a), uniqueLinks(b)
+	if len(a) != len(b) {
+		return false
 	}
+	for _, l := range a {
+		found := false
+		for _, m := range b {
+			if sameLink(l, m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateLinks replaces the links of the object bucket/key with those update
+// returns when passed its current links.
+//
+// The links of all siblings are passed, and the object is stored back as a
+// single value: that of the sibling picked by the client's SiblingResolver,
+// keeping its content type, usermeta and indexes, and updating its LinkIndex
+// if link indexing is enabled. The update is sent with the fetched vclock and
+// only applies if the object wasn't modified since; if it was, the object is
+// fetched again and update called again, up to LinkRetries times, after
+// which ErrModified is returned. Nothing is stored when the links don't
+// change.
+func (c *Client) UpdateLinks(bucket, key string, update func(links []*RpbLink) []*RpbLink) (err error) {
+	c, span := c.startOperation("UpdateLinks")
+	span.SetAttribute(AttrBucket, bucket)
+	span.SetAttribute(AttrKey, key)
+	attempts := 0
+	defer func() {
+		span.SetAttribute(AttrAttempts, attempts)
+		endSpan(span, err)
+	}()
+
+	for attempt := 0; ; attempt++ {
+		attempts++
+		obj, err := c.FetchObject(bucket, key)
+		if err != nil {
+			return err
+		}
+
+		content := c.resolveSiblings(obj.GetContent())
+		if content == nil {
+			return ErrNoContent
+		}
+
+		var current []*RpbLink
+		for _, sibling := range obj.GetContent() {
+			current = append(current, sibling.GetLinks()...)
+		}
+		current = uniqueLinks(current)
+
+		links := uniqueLinks(update(current))
+		if len(obj.GetContent()) == 1 && sameLinks(links, content.GetLinks()) {
+			return nil
+		}
 
-	if len(obj.GetContent()) == 0 {
-		return ErrNoContent
+		stored := &RpbContent{
+			Value:           content.GetValue(),
+			ContentType:     content.GetContentType(),
+			Charset:         content.GetCharset(),
+			ContentEncoding: content.GetContentEncoding(),
+			Links:           links,
+			Usermeta:        content.GetUsermeta(),
+			Indexes:         content.GetIndexes(),
+		}
+		if c.linkIndexing {
+			indexLinks(stored)
+		}
+
+		_, err = c.StoreObject(bucket, key, stored, &PutOptions{Vclock: obj.GetVclock(), IfNotModified: true})
+		if err == ErrModified && attempt < LinkRetries {
+			continue
+		}
+		return err
 	}
+}
 
+// LinkAdd sets a link reference to the link bucket/key in bucket/key, unless
+// it is set already. See UpdateLinks.
+//
+// Note that this can be manually done by passing RpbContent to StoreObject.
+func (c *Client) LinkAdd(bucket, key, lbucket, lkey, ltag string) error {
 	link := &RpbLink{
 		Bucket: []byte(lbucket),
 		Key:    []byte(lkey),
 		Tag:    []byte(ltag),
 	}
-	obj.Content[0].Links = append(obj.Content[0].Links, link)
-
-	if _, err := c.StoreObject(bucket, key, obj.GetContent()[0]); err != nil {
-		return err
-	}
 
-	return nil
+	return c.UpdateLinks(bucket, key, func(links []*RpbLink) []*RpbLink {
+		return append(links, link)
+	})
 }
 
 // LinkWalk is just a synonymn for FetchObject.  It expects the link bucket/key.
+//
+// Use Walk to follow the links of an object.
 func (c *Client) LinkWalk(bucket, key string) (*RpbGetResp, error) {
 	return c.FetchObject(bucket, key)
 }
 
-// LinkRemove removes the associated link bucket/key from the bucket/key.
+// LinkRemove removes the links to bucket/key from the bucket/key, whatever
+// their tag. See UpdateLinks.
 func (c *Client) LinkRemove(bucket, key, lbucket, lkey string) error {
-	obj, err := c.FetchObject(bucket, key)
-	if err != nil {
-		return err
-	}
-
-	if len(obj.GetContent()) == 0 {
-		return ErrNoContent
-	}
-
-	for i, k := range obj.GetContent()[0].GetLinks() {
-		if string(k.GetBucket()) == lbucket && string(k.GetKey()) == lkey {
-			obj.Content[0].Links[i] = obj.Content[0].Links[len(obj.Content[0].Links)-1]
-			obj.Content[0].Links = obj.Content[0].Links[0 : len(obj.Content[0].Links)-1]
-			break
+	return c.UpdateLinks(bucket, key, func(links []*RpbLink) []*RpbLink {
+		kept := links[:0]
+		for _, l := range links {
+			if string(l.GetBucket()) == lbucket && string(l.GetKey()) == lkey {
+				continue
+			}
+			kept = append(kept, l)
 		}
-	}
-
-	if _, err := c.StoreObject(bucket, key, obj.GetContent()[0]); err != nil {
-		return err
-	}
+		return kept
+	})
+}
 
-	return nil
+// SetLinks replaces all links of bucket/key at once. See UpdateLinks.
+func (c *Client) SetLinks(bucket, key string, links []*RpbLink) error {
+	return c.UpdateLinks(bucket, key, func([]*RpbLink) []*RpbLink {
+		return links
+	})
 }
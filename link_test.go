@@ -1,6 +1,7 @@
 package riakpbc
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -58,3 +59,161 @@ func TestLink(t *testing.T) {
 
 	teardownData(t, riak)
 }
+
+func linkTo(bucket, key, tag string) *RpbLink {
+	return &RpbLink{Bucket: []byte(bucket), Key: []byte(key), Tag: []byte(tag)}
+}
+
+func TestLinkUpdates(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	if err := riak.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer riak.Close()
+
+	riak.StoreObject("people", "bob", &RpbContent{
+		Value:       []byte(`{"name":"bob"}`),
+		ContentType: []byte("application/json"),
+		Usermeta:    []*RpbPair{{Key: []byte("owner"), Value: []byte("admin")}},
+		Indexes:     []*RpbPair{{Key: []byte("name_bin"), Value: []byte("bob")}},
+	})
+
+	if err := riak.LinkAdd("people", "bob", "people", "alice", "friend"); err != nil {
+		t.Fatal(err)
+	}
+	vclock := server.object("people", "bob").vclock
+
+	// Adding the same link again stores nothing
+	if err := riak.LinkAdd("people", "bob", "people", "alice", "friend"); err != nil {
+		t.Fatal(err)
+	}
+	if string(server.object("people", "bob").vclock) != string(vclock) {
+		t.Error("expected an unchanged link set not to be stored")
+	}
+
+	put := &RpbPutReq{}
+	riak.LinkAdd("people", "bob", "people", "carol", "friend")
+	server.lastRequest("RpbPutReq", put)
+	if string(put.GetVclock()) != string(vclock) || !put.GetIfNotModified() {
+		t.Error("expected the link update to carry the fetched vclock")
+	}
+
+	obj, _ := riak.FetchObject("people", "bob")
+	content := obj.GetContent()[0]
+	if len(content.GetLinks()) != 2 {
+		t.Errorf("expected 2 links, got %d", len(content.GetLinks()))
+	}
+	if string(content.GetValue()) != `{"name":"bob"}` || string(content.GetContentType()) != "application/json" {
+		t.Error("expected the value to be preserved")
+	}
+	if len(content.GetUsermeta()) != 1 || len(content.GetIndexes()) != 1 {
+		t.Error("expected usermeta and indexes to be preserved")
+	}
+
+	riak.LinkAdd("people", "bob", "people", "alice", "colleague")
+	if err := riak.LinkRemove("people", "bob", "people", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ = riak.FetchObject("people", "bob")
+	if links := obj.GetContent()[0].GetLinks(); len(links) != 1 || string(links[0].GetKey()) != "carol" {
+		t.Errorf("expected only the link to carol to remain, got %v", links)
+	}
+
+	if err := riak.SetLinks("people", "bob", []*RpbLink{linkTo("people", "dave", "friend"), linkTo("people", "dave", "friend")}); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ = riak.FetchObject("people", "bob")
+	if links := obj.GetContent()[0].GetLinks(); len(links) != 1 || string(links[0].GetKey()) != "dave" {
+		t.Errorf("expected only the link to dave, got %v", links)
+	}
+
+	if err := riak.LinkAdd("people", "nobody", "people", "bob", "friend"); err != ErrObjectNotFound {
+		t.Errorf("expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestLinkSiblings(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	if err := riak.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer riak.Close()
+
+	older, newer := uint32(100), uint32(200)
+	server.Lock()
+	server.objects["people/bob"] = &memObject{
+		vclock: []byte("siblings"),
+		content: []*RpbContent{
+			{Value: []byte("new"), LastMod: &newer, Links: []*RpbLink{linkTo("people", "alice", "friend")}},
+			{Value: []byte("old"), LastMod: &older, Links: []*RpbLink{linkTo("people", "carol", "friend"), linkTo("people", "alice", "friend")}},
+		},
+	}
+	server.Unlock()
+
+	if err := riak.LinkAdd("people", "bob", "people", "dave", "friend"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, _ := riak.FetchObject("people", "bob")
+	if len(obj.GetContent()) != 1 {
+		t.Fatalf("expected the siblings to be resolved, got %d", len(obj.GetContent()))
+	}
+	if string(obj.GetContent()[0].GetValue()) != "new" {
+		t.Error("expected the latest sibling to be kept")
+	}
+	if len(obj.GetContent()[0].GetLinks()) != 3 {
+		t.Errorf("expected the links of all siblings, got %v", obj.GetContent()[0].GetLinks())
+	}
+
+	riak.SetSiblingResolver(func(siblings []*RpbContent) *RpbContent {
+		return nil
+	})
+	if err := riak.LinkAdd("people", "bob", "people", "erin", "friend"); err != ErrNoContent {
+		t.Errorf("expected ErrNoContent, got %v", err)
+	}
+}
+
+func TestLinkRetries(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	if err := riak.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer riak.Close()
+
+	riak.StoreObject("people", "bob", "bob")
+
+	// A concurrent write lands between every fetch and store
+	puts := 0
+	server.Lock()
+	server.beforePut = func() {
+		puts++
+		if puts <= 2 {
+			server.objects["people/bob"].vclock = []byte(fmt.Sprintf("concurrent%d", puts))
+		}
+	}
+	server.Unlock()
+
+	if err := riak.LinkAdd("people", "bob", "people", "alice", "friend"); err != nil {
+		t.Fatal(err)
+	}
+	if puts != 3 {
+		t.Errorf("expected 3 attempts, got %d", puts)
+	}
+
+	server.Lock()
+	server.beforePut = func() {
+		puts++
+		server.objects["people/bob"].vclock = []byte(fmt.Sprintf("concurrent%d", puts))
+	}
+	server.Unlock()
+
+	if err := riak.LinkAdd("people", "bob", "people", "carol", "friend"); err != ErrModified {
+		t.Errorf("expected ErrModified, got %v", err)
+	}
+}
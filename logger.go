@@ -0,0 +1,69 @@
+package riakpbc
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Logger is a leveled, structured logger. Each message is followed by
+// alternating key and value fields, e.g.
+//
+//	logger.Warn("slow request", "node", "127.0.0.1:8087", "latency", d)
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keyvals ...interface{}) {}
+func (nopLogger) Info(msg string, keyvals ...interface{})  {}
+func (nopLogger) Warn(msg string, keyvals ...interface{})  {}
+func (nopLogger) Error(msg string, keyvals ...interface{}) {}
+
+// StdLogger adapts a standard library *log.Logger to Logger, printing lines
+// such as `[WARN] slow request node=127.0.0.1:8087 latency=1.2s`.
+type StdLogger struct {
+	logger *log.Logger
+}
+
+// NewStdLogger returns a Logger printing to logger.
+func NewStdLogger(logger *log.Logger) *StdLogger {
+	return &StdLogger{logger: logger}
+}
+
+func (l *StdLogger) Debug(msg string, keyvals ...interface{}) { l.print("DEBUG", msg, keyvals) }
+func (l *StdLogger) Info(msg string, keyvals ...interface{})  { l.print("INFO", msg, keyvals) }
+func (l *StdLogger) Warn(msg string, keyvals ...interface{})  { l.print("WARN", msg, keyvals) }
+func (l *StdLogger) Error(msg string, keyvals ...interface{}) { l.print("ERROR", msg, keyvals) }
+
+func (l *StdLogger) print(level, msg string, keyvals []interface{}) {
+	line := []string{"[" + level + "]", msg}
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			line = append(line, fmt.Sprintf("%v=%v", keyvals[i], keyvals[i+1]))
+		} else {
+			line = append(line, fmt.Sprint(keyvals[i]))
+		}
+	}
+	l.logger.Print(strings.Join(line, " "))
+}
+
+// SlogLogger adapts a log/slog *slog.Logger to Logger.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to logger.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+func (l *SlogLogger) Debug(msg string, keyvals ...interface{}) { l.logger.Debug(msg, keyvals...) }
+func (l *SlogLogger) Info(msg string, keyvals ...interface{})  { l.logger.Info(msg, keyvals...) }
+func (l *SlogLogger) Warn(msg string, keyvals ...interface{})  { l.logger.Warn(msg, keyvals...) }
+func (l *SlogLogger) Error(msg string, keyvals ...interface{}) { l.logger.Error(msg, keyvals...) }
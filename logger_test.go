@@ -0,0 +1,26 @@
+package riakpbc
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Warn("slow request", "node", "127.0.0.1:8087", "operation", "RpbGetReq")
+	assert.T(t, buf.String() == "[WARN] slow request node=127.0.0.1:8087 operation=RpbGetReq\n")
+}
+
+func TestNodeLogsStateChanges(t *testing.T) {
+	var buf bytes.Buffer
+	node, err := NewNode("127.0.0.1:8087", NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.SetLogger(NewStdLogger(log.New(&buf, "", 0)))
+
+	node.RecordError(1.0)
+	assert.T(t, strings.Contains(buf.String(), "[WARN] node circuit opened node=127.0.0.1:8087 from=healthy"))
+}
@@ -0,0 +1,90 @@
+package riakpbc
+
+import (
+	"time"
+)
+
+// Meta is the metadata of a stored object: everything but its value.
+type Meta struct {
+	Key             string
+	Vclock          []byte
+	VTag            string
+	LastModified    time.Time
+	ContentType     string
+	Charset         string
+	ContentEncoding string
+	Usermeta        map[string]string
+	Indexes         []*RpbPair
+	Links           []*RpbLink
+	Deleted         bool
+	Siblings        int // number of sibling values the object has
+}
+
+// newMeta builds the Meta of an object from one of its sibling contents.
+func newMeta(key string, vclock []byte, content *RpbContent, siblings int) *Meta {
+	meta := &Meta{
+		Key:             key,
+		Vclock:          vclock,
+		VTag:            string(content.GetVtag()),
+		ContentType:     string(content.GetContentType()),
+		Charset:         string(content.GetCharset()),
+		ContentEncoding: string(content.GetContentEncoding()),
+		Indexes:         content.GetIndexes(),
+		Links:           content.GetLinks(),
+		Deleted:         content.GetDeleted(),
+		Siblings:        siblings,
+	}
+
+	if content.LastMod != nil {
+		meta.LastModified = time.Unix(int64(content.GetLastMod()), int64(content.GetLastModUsecs())*int64(time.Microsecond))
+	}
+
+	if len(content.GetUsermeta()) > 0 {
+		meta.Usermeta = make(map[string]string, len(content.GetUsermeta()))
+		for _, pair := range content.GetUsermeta() {
+			meta.Usermeta[string(pair.GetKey())] = string(pair.GetValue())
+		}
+	}
+
+	return meta
+}
+
+// FetchMeta returns the metadata of an object without fetching its value.
+// When the object has siblings the metadata is that of the first.
+//
+// With GetOptions.Deleted the Meta of a deleted object carries its vclock and
+// Deleted is set, rather than ErrObjectNotFound being returned.
+func (c *Client) FetchMeta(bucket, key string, opts ...*GetOptions) (*Meta, error) {
+	return c.fetchMeta("FetchMeta", bucket, key, opts)
+}
+
+func (c *Client) fetchMeta(operation, bucket, key string, opts []*GetOptions) (*Meta, error) {
+	req := c.getRequest(bucket, key, opts)
+	req.Head = flag(true)
+
+	response, err := c.fetchObject(operation, req, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if IsTombstone(response) && len(response.GetContent()) == 0 {
+		return &Meta{Key: key, Vclock: response.GetVclock(), Deleted: true}, nil
+	}
+	if len(response.GetContent()) == 0 {
+		return nil, ErrObjectNotFound
+	}
+
+	return newMeta(key, response.GetVclock(), response.GetContent()[0], len(response.GetContent())), nil
+}
+
+// Exists reports whether an object is stored under key, without fetching its
+// value. Deleted objects don't exist.
+func (c *Client) Exists(bucket, key string, opts ...*GetOptions) (bool, error) {
+	meta, err := c.fetchMeta("Exists", bucket, key, opts)
+	if err == ErrObjectNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !meta.Deleted, nil
+}
@@ -0,0 +1,77 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"testing"
+	"time"
+)
+
+func TestNewMeta(t *testing.T) {
+	deleted := true
+	lastMod, lastModUsecs := uint32(1375488000), uint32(250)
+	content := &RpbContent{
+		Value:        []byte("value"),
+		ContentType:  []byte("text/plain"),
+		Vtag:         []byte("vtag"),
+		LastMod:      &lastMod,
+		LastModUsecs: &lastModUsecs,
+		Usermeta:     []*RpbPair{{Key: []byte("owner"), Value: []byte("bob")}},
+		Indexes:      []*RpbPair{{Key: []byte("email_bin"), Value: []byte("bob@example.com")}},
+		Links:        []*RpbLink{{Bucket: []byte("people"), Key: []byte("alice")}},
+		Deleted:      &deleted,
+	}
+
+	meta := newMeta("key", []byte("vclock"), content, 2)
+	assert.Equal(t, "key", meta.Key)
+	assert.Equal(t, []byte("vclock"), meta.Vclock)
+	assert.Equal(t, "vtag", meta.VTag)
+	assert.Equal(t, "text/plain", meta.ContentType)
+	assert.Equal(t, time.Unix(1375488000, 250000).UTC(), meta.LastModified.UTC())
+	assert.Equal(t, map[string]string{"owner": "bob"}, meta.Usermeta)
+	assert.Equal(t, "email_bin", string(meta.Indexes[0].GetKey()))
+	assert.Equal(t, "alice", string(meta.Links[0].GetKey()))
+	assert.T(t, meta.Deleted)
+	assert.Equal(t, 2, meta.Siblings)
+
+	meta = newMeta("key", nil, &RpbContent{}, 1)
+	assert.T(t, meta.LastModified.IsZero())
+	assert.T(t, meta.Usermeta == nil)
+}
+
+func TestFetchMeta(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.SetCompression(&GzipCompressor{}, 0)
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	exists, err := riak.Exists("bucket", "key")
+	assert.T(t, err == nil)
+	assert.T(t, !exists)
+
+	_, err = riak.FetchMeta("bucket", "key")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	_, err = riak.StoreObject("bucket", "key", &RpbContent{
+		Value:       []byte("value"),
+		ContentType: []byte("text/plain"),
+		Usermeta:    []*RpbPair{{Key: []byte("owner"), Value: []byte("bob")}},
+	})
+	assert.T(t, err == nil)
+
+	meta, err := riak.FetchMeta("bucket", "key")
+	assert.T(t, err == nil)
+	assert.Equal(t, []byte("vclock1"), meta.Vclock)
+	assert.Equal(t, "text/plain", meta.ContentType)
+	assert.Equal(t, "gzip", meta.ContentEncoding)
+	assert.Equal(t, "bob", meta.Usermeta["owner"])
+
+	req := &RpbGetReq{}
+	server.lastRequest("RpbGetReq", req)
+	assert.T(t, req.GetHead())
+
+	exists, err = riak.Exists("bucket", "key")
+	assert.T(t, err == nil)
+	assert.T(t, exists)
+}
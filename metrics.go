@@ -0,0 +1,92 @@
+package riakpbc
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// Error classes reported to Metrics, see ErrorClass().
+const (
+	ErrorClassNone     = ""
+	ErrorClassNotFound = "notfound"
+	ErrorClassTimeout  = "timeout"
+	ErrorClassNetwork  = "network"
+	ErrorClassProtocol = "protocol"
+	ErrorClassRiak     = "riak"
+)
+
+// RequestMetric describes a single request/response round trip on a Node.
+type RequestMetric struct {
+	Operation string        // request message name, e.g. "RpbGetReq"
+	Node      string        // address of the node that served the request
+	Latency   time.Duration // time from write to the end of the read
+	BytesOut  int           // bytes written, including the message header
+	BytesIn   int           // bytes read, including the message header
+	Error     string        // error class, ErrorClassNone on success
+}
+
+// Metrics is notified after every *Node.ReqResp().
+//
+// ObserveRequest is called on the request path, possibly from several
+// goroutines at once, so implementations should be safe for concurrent use
+// and return quickly.
+type Metrics interface {
+	ObserveRequest(metric *RequestMetric)
+}
+
+// ErrorClass groups an error returned by a request into a coarse class
+// suitable for use as a metric label.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	switch err {
+	case ErrObjectNotFound:
+		return ErrorClassNotFound
+	case ErrCorruptHeader, ErrNoSuchCommand, ErrLengthZero:
+		return ErrorClassProtocol
+	case ErrReadTimeout, ErrWriteTimeout:
+		return ErrorClassTimeout
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ErrorClassNetwork
+	}
+
+	if nerr, ok := err.(net.Error); ok {
+		if nerr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassRiak
+}
+
+// SetMetrics sets the Metrics notified after each request on the node.
+func (node *Node) SetMetrics(metrics Metrics) {
+	node.setHooks(func(hooks *nodeHooks) {
+		hooks.metrics = metrics
+	})
+}
+
+// finish logs slow requests and notifies Metrics once a request completes.
+func (node *Node) finish(structname string, latency time.Duration, bytesOut, bytesIn int, err error) {
+	hooks := node.hooks.Load()
+	if hooks.slowRequest > 0 && latency >= hooks.slowRequest {
+		hooks.logger.Warn("slow request", "node", node.addr, "operation", structname, "latency", latency)
+	}
+
+	if hooks.metrics == nil {
+		return
+	}
+
+	hooks.metrics.ObserveRequest(&RequestMetric{
+		Operation: structname,
+		Node:      node.addr,
+		Latency:   latency,
+		BytesOut:  bytesOut,
+		BytesIn:   bytesIn,
+		Error:     ErrorClass(err),
+	})
+}
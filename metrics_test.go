@@ -0,0 +1,114 @@
+package riakpbc
+
+import (
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	assert.T(t, ErrorClass(nil) == ErrorClassNone)
+	assert.T(t, ErrorClass(ErrObjectNotFound) == ErrorClassNotFound)
+	assert.T(t, ErrorClass(ErrCorruptHeader) == ErrorClassProtocol)
+	assert.T(t, ErrorClass(io.EOF) == ErrorClassNetwork)
+	assert.T(t, ErrorClass(errors.New("0: no such bucket")) == ErrorClassRiak)
+}
+
+type countingMetrics struct {
+	requests int64
+}
+
+func (m *countingMetrics) ObserveRequest(metric *RequestMetric) {
+	atomic.AddInt64(&m.requests, 1)
+}
+
+func TestNodeHooksWhileRequesting(t *testing.T) {
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		if structname == "RpbListKeysReq" {
+			return "RpbListKeysResp", &RpbListKeysResp{Done: proto.Bool(true)}
+		}
+		return "RpbPingResp", nil
+	})
+
+	node, err := NewNode(addr, NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.EnablePipelining(4)
+	defer node.Close()
+
+	metrics := &countingMetrics{}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// Hooks are replaced and the connection checked while pipelined and
+	// serial requests finish, which the race detector checks
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			node.SetLogger(nopLogger{})
+			node.SetSlowRequestThreshold(time.Nanosecond)
+			node.SetMetrics(metrics)
+			node.Connected()
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				node.Ping()
+				node.ReqMultiResp(&RpbListKeysReq{Bucket: []byte("bucket")}, "RpbListKeysReq")
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	assert.T(t, atomic.LoadInt64(&metrics.requests) > 0)
+}
+
+func TestPoolLoggerWhileRequesting(t *testing.T) {
+	// Requests while every node is down log from the pool
+	riak := NewClient([]string{"127.0.0.1:1"})
+	for _, node := range riak.Pool().Nodes() {
+		node.RecordError(10.0)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			riak.SetLogger(nopLogger{})
+			riak.DisableLogging()
+		}
+	}()
+
+	for end := time.Now().Add(50 * time.Millisecond); time.Now().Before(end); {
+		_, err := riak.Ping()
+		assert.Equal(t, ErrAllNodesDown, err)
+	}
+
+	close(done)
+	wg.Wait()
+}
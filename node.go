@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,11 +18,32 @@ type Node struct {
 	readTimeout  time.Duration
 	writeTimeout time.Duration
 	errorRate    *Decaying
-	ok           bool
-	oklock       *sync.Mutex
+	state        NodeState
+	stateChanged time.Time
+	probes       int
+	breaker      *BreakerConfig
+	stateFuncs   []StateChangeFunc
+	changes      []stateChange
+	firing       bool
+	statelock    *sync.Mutex
+	checking     int32
+	bytesIn      int
+	bytesOut     int
+	hooks        atomic.Pointer[nodeHooks]
+	connected    atomic.Bool
+	pipe         atomic.Pointer[pipeline]
 	sync.Mutex
 }
 
+// nodeHooks are the observers of a node. They are replaced as a whole, under
+// the state lock, so that requests finishing outside of it read a consistent
+// snapshot.
+type nodeHooks struct {
+	logger      Logger
+	metrics     Metrics
+	slowRequest time.Duration
+}
+
 // Returns a new Node.
 func NewNode(addr string, readTimeout, writeTimeout time.Duration) (*Node, error) {
 	tcpaddr, err := net.ResolveTCPAddr("tcp", addr)
@@ -35,9 +57,15 @@ func NewNode(addr string, readTimeout, writeTimeout time.Duration) (*Node, error
 		readTimeout:  readTimeout,
 		writeTimeout: writeTimeout,
 		errorRate:    NewDecaying(),
-		ok:           true,
-		oklock:       &sync.Mutex{},
+		state:        NodeHealthy,
+		stateChanged: time.Now(),
+		breaker:      DefaultBreakerConfig(),
+		statelock:    &sync.Mutex{},
 	}
+	node.hooks.Store(&nodeHooks{
+		logger:      nopLogger{},
+		slowRequest: NODE_SLOW_REQUEST,
+	})
 
 	return node, nil
 }
@@ -46,50 +74,154 @@ func NewNode(addr string, readTimeout, writeTimeout time.Duration) (*Node, error
 func (node *Node) Dial() (err error) {
 	node.conn, err = net.DialTCP("tcp", nil, node.tcpAddr)
 	if err != nil {
+		node.connected.Store(false)
+		node.log().Warn("node dial failed", "node", node.addr, "error", err)
 		return err
 	}
 
 	node.conn.SetKeepAlive(true)
+	node.connected.Store(true)
+	node.log().Debug("node dialed", "node", node.addr)
 
 	return nil
 }
 
+// SetLogger sets the Logger for connection lifecycle, state changes and slow
+// requests of the node.
+func (node *Node) SetLogger(logger Logger) {
+	node.setHooks(func(hooks *nodeHooks) {
+		hooks.logger = logger
+	})
+}
+
+// SetSlowRequestThreshold sets the latency above which requests are logged as
+// slow. Zero disables slow request logging.
+func (node *Node) SetSlowRequestThreshold(threshold time.Duration) {
+	node.setHooks(func(hooks *nodeHooks) {
+		hooks.slowRequest = threshold
+	})
+}
+
+// setHooks replaces the hooks of the node with a copy modified by set.
+func (node *Node) setHooks(set func(hooks *nodeHooks)) {
+	node.statelock.Lock()
+	defer node.statelock.Unlock()
+
+	hooks := *node.hooks.Load()
+	set(&hooks)
+	node.hooks.Store(&hooks)
+}
+
+// log returns the Logger of the node.
+func (node *Node) log() Logger {
+	return node.hooks.Load().logger
+}
+
+// Addr returns the address the node dials.
+func (node *Node) Addr() string {
+	return node.addr
+}
+
 // ErrorRate safely returns the current Node's error rate.
 func (node *Node) ErrorRate() float64 {
 	return node.errorRate.Value()
 }
 
-// RecordError increments the current error value - see decaying.go
-func (node *Node) RecordError(amount float64) {
-	node.SetOk(false)
-	node.errorRate.Add(amount)
-}
-
+// GetOk reports whether the node is in the healthy state.
 func (node *Node) GetOk() bool {
-	var out bool
-	node.oklock.Lock()
-	out = node.ok
-	node.oklock.Unlock()
-	return out
+	return node.State() == NodeHealthy
 }
 
+// SetOk forces the node into the healthy state, or a healthy node into the
+// degraded state.
 func (node *Node) SetOk(ok bool) {
-	node.oklock.Lock()
-	node.ok = ok
-	node.oklock.Unlock()
+	defer node.fireStateChanges()
+	node.setState(func(state NodeState, rate float64, breaker *BreakerConfig) NodeState {
+		if ok {
+			return NodeHealthy
+		}
+		if state == NodeHealthy {
+			return NodeDegraded
+		}
+		return state
+	})
 }
 
+// IsConnected reports whether the node has a connection open. It reads the
+// connection unlocked and is meant for the node's own request path; use
+// Connected from other goroutines.
 func (node *Node) IsConnected() bool {
 	return node.conn != nil
 }
 
+// Connected reports whether the node has a connection open, and is safe to
+// call while requests are in flight.
+func (node *Node) Connected() bool {
+	return node.connected.Load()
+}
+
+// Connections returns the number of connections the node has open: its own
+// and, with pipelining enabled, the pipelined one. Like Connected it is safe
+// to call while requests are in flight.
+func (node *Node) Connections() int {
+	n := 0
+	if node.connected.Load() {
+		n++
+	}
+	if pipe := node.pipe.Load(); pipe != nil && pipe.open.Load() {
+		n++
+	}
+	return n
+}
+
 func (node *Node) ReqResp(reqstruct interface{}, structname string, raw bool) (response interface{}, err error) {
+	response, _, err = node.reqResp(reqstruct, structname, raw)
+	return
+}
+
+// reqResp is ReqResp, additionally returning the size of the response read.
+func (node *Node) reqResp(reqstruct interface{}, structname string, raw bool) (response interface{}, size int, err error) {
+	if pipe := node.pipe.Load(); pipe != nil {
+		return pipe.reqResp(reqstruct, structname, raw)
+	}
+	return node.serialReqResp(reqstruct, structname, raw)
+}
+
+// serialReqResp performs a request on the node's own connection, holding the
+// node lock for the whole round trip.
+func (node *Node) serialReqResp(reqstruct interface{}, structname string, raw bool) (response interface{}, size int, err error) {
+	size, err = node.serialReqFrames(reqstruct, structname, raw, func(frame interface{}) bool {
+		response = frame
+		return true
+	})
+	if err != nil {
+		return nil, size, err
+	}
+	return response, size, nil
+}
+
+// serialReqFrames performs a request on the node's own connection and passes
+// every response message to frame until it reports the last one. The node
+// lock is held from the write until then, so that no other request reads the
+// messages of this one, and frame must not call back into the node. The
+// connection is closed if a message can't be read.
+func (node *Node) serialReqFrames(reqstruct interface{}, structname string, raw bool, frame func(response interface{}) (done bool)) (size int, err error) {
+	defer node.fireStateChanges()
 	node.Lock()
 	defer node.Unlock()
+
+	begin := time.Now()
+	node.bytesIn, node.bytesOut = 0, 0
+	defer func() {
+		size = node.bytesIn
+		node.finish(structname, time.Since(begin), node.bytesOut, node.bytesIn, err)
+	}()
+
 	if node.IsConnected() != true {
 		err = node.Dial()
 		if err != nil {
-			return nil, err
+			node.recordError(1.0)
+			return 0, err
 		}
 	}
 	if raw == true {
@@ -99,51 +231,150 @@ func (node *Node) ReqResp(reqstruct interface{}, structname string, raw bool) (r
 	}
 
 	if err != nil {
-		node.Close()
-		return nil, err
+		node.closeConn()
+		return 0, err
 	}
 
-	response, err = node.response()
-	if err != nil {
-		node.Close()
-		return nil, err
+	for {
+		response, err := node.response()
+		if err != nil {
+			node.closeConn()
+			return 0, err
+		}
+		if frame(response) {
+			break
+		}
 	}
 
+	node.recordSuccess()
+
 	return
 }
 
 func (node *Node) ReqMultiResp(reqstruct interface{}, structname string) (response interface{}, err error) {
-	response, err = node.ReqResp(reqstruct, structname, false)
-	if err != nil {
-		return nil, err
-	}
+	response, _, err = node.reqMultiResp(reqstruct, structname)
+	return
+}
 
+// reqMultiResp is ReqMultiResp, additionally returning the size of the
+// responses read.
+func (node *Node) reqMultiResp(reqstruct interface{}, structname string) (response interface{}, size int, err error) {
 	if structname == "RpbListKeysReq" {
-		keys := response.(*RpbListKeysResp).GetKeys()
-		done := response.(*RpbListKeysResp).GetDone()
-		for done != true {
-			response, err := node.response()
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, response.(*RpbListKeysResp).GetKeys()...)
-			done = response.(*RpbListKeysResp).GetDone()
+		var keys [][]byte
+		size, err = node.serialReqFrames(reqstruct, structname, false, func(frame interface{}) bool {
+			keys = append(keys, frame.(*RpbListKeysResp).GetKeys()...)
+			return frame.(*RpbListKeysResp).GetDone()
+		})
+		if err != nil {
+			return nil, size, err
 		}
-		return keys, nil
+		return keys, size, nil
 	} else if structname == "RpbMapRedReq" {
-		mapResponse := response.(*RpbMapRedResp).GetResponse()
-		done := response.(*RpbMapRedResp).GetDone()
-		for done != true {
-			response, err := node.response()
-			if err != nil {
-				return nil, err
+		var mapResponse []byte
+		size, err = node.serialReqFrames(reqstruct, structname, false, func(frame interface{}) bool {
+			mapResponse = append(mapResponse, frame.(*RpbMapRedResp).GetResponse()...)
+			return frame.(*RpbMapRedResp).GetDone()
+		})
+		if err != nil {
+			return nil, size, err
+		}
+		return mapResponse, size, nil
+	}
+	return nil, 0, nil
+}
+
+// ReqBucketsStream sends a streaming ListBuckets request and calls fn with the
+// buckets of each response message, until the one marked done. The listing
+// is read on a connection of its own, one message at a time as fn returns,
+// so Riak waits for a slow fn rather than the listing piling up in memory,
+// and fn may send requests of its own to the node. Once fn returns an error
+// the listing is cut off and that error returned.
+func (node *Node) ReqBucketsStream(reqstruct *RpbListBucketsReq, fn func(buckets [][]byte) error) error {
+	_, err := node.reqBucketsStream(reqstruct, fn)
+	return err
+}
+
+// reqBucketsStream is ReqBucketsStream, additionally returning the size of the
+// responses read.
+func (node *Node) reqBucketsStream(reqstruct *RpbListBucketsReq, fn func(buckets [][]byte) error) (size int, err error) {
+	defer node.fireStateChanges()
+
+	begin := time.Now()
+	bytesOut := 0
+	defer func() {
+		node.finish("RpbListBucketsReq", time.Since(begin), bytesOut, size, err)
+	}()
+
+	marshaledRequest, err := proto.Marshal(reqstruct)
+	if err != nil {
+		return 0, err
+	}
+	formattedRequest, err := prependRequestHeader("RpbListBucketsReq", marshaledRequest)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := net.DialTCP("tcp", nil, node.tcpAddr)
+	if err != nil {
+		node.recordError(1.0)
+		return 0, err
+	}
+	defer conn.Close()
+
+	bytesOut, err = node.writeTo(conn, formattedRequest)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		rawresp, n, err := node.readFrom(conn)
+		size += n
+		if err != nil {
+			return size, err
+		}
+		if err := validateResponseHeader(rawresp); err != nil {
+			node.recordError(1.0)
+			return size, err
+		}
+		response, err := unmarshalResponse(rawresp)
+		if err != nil {
+			return size, err
+		}
+
+		buckets := response.(*RpbListBucketsResp)
+		if len(buckets.GetBuckets()) > 0 {
+			if err := fn(buckets.GetBuckets()); err != nil {
+				return size, err
 			}
-			mapResponse = append(mapResponse, response.(*RpbMapRedResp).GetResponse()...)
-			done = response.(*RpbMapRedResp).GetDone()
 		}
-		return mapResponse, nil
+		if buckets.GetDone() {
+			break
+		}
 	}
-	return nil, nil
+
+	node.recordSuccess()
+
+	return size, nil
+}
+
+// ReqMapRedPhases sends a MapReduce request and returns every response
+// message, each carrying the phase its results belong to.
+func (node *Node) ReqMapRedPhases(reqstruct *RpbMapRedReq) ([]*RpbMapRedResp, error) {
+	responses, _, err := node.reqMapRedPhases(reqstruct)
+	return responses, err
+}
+
+// reqMapRedPhases is ReqMapRedPhases, additionally returning the size of the
+// responses read.
+func (node *Node) reqMapRedPhases(reqstruct *RpbMapRedReq) (responses []*RpbMapRedResp, size int, err error) {
+	size, err = node.serialReqFrames(reqstruct, "RpbMapRedReq", false, func(frame interface{}) bool {
+		responses = append(responses, frame.(*RpbMapRedResp))
+		return frame.(*RpbMapRedResp).GetDone()
+	})
+	if err != nil {
+		return nil, size, err
+	}
+	return responses, size, nil
 }
 
 func (node *Node) Ping() bool {
@@ -157,26 +388,72 @@ func (node *Node) Ping() bool {
 	return true
 }
 
-// Close the connection
+// HealthCheck pings the node and redials it if the ping fails.
+//
+// Nodes with an open circuit are left alone until they turn half-open, at
+// which point the ping acts as a probe. Only one check runs per node at a
+// time; a call made while another is in flight returns immediately.
+func (node *Node) HealthCheck() {
+	if !atomic.CompareAndSwapInt32(&node.checking, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&node.checking, 0)
+
+	if node.State() == NodeOpen {
+		return
+	}
+
+	if node.Ping() == false {
+		node.RecordError(0.1)
+		node.log().Warn("node ping failed, redialing", "node", node.addr)
+		node.Lock()
+		node.Close()
+		node.Dial()
+		node.Unlock()
+	}
+}
+
+// Close the connection, along with the pipelined one if pipelining is
+// enabled.
 func (node *Node) Close() {
+	if pipe := node.pipe.Load(); pipe != nil {
+		pipe.close()
+	}
+
+	node.closeConn()
+}
+
+// closeConn closes the node's own connection, leaving requests in flight on
+// the pipelined one alone.
+func (node *Node) closeConn() {
 	if node.conn != nil {
 		node.conn.Close()
+		node.log().Debug("node connection closed", "node", node.addr)
 	}
 
 	node.conn = nil
+	node.connected.Store(false)
 }
 
 func (node *Node) read() (respraw []byte, err error) {
-	node.conn.SetReadDeadline(time.Now().Add(node.readTimeout))
+	respraw, n, err := node.readFrom(node.conn)
+	node.bytesIn += n
+	return respraw, err
+}
+
+// readFrom reads a single message from conn, returning it along with the
+// number of bytes read.
+func (node *Node) readFrom(conn *net.TCPConn) (respraw []byte, n int, err error) {
+	conn.SetReadDeadline(time.Now().Add(node.readTimeout))
 
 	buf := make([]byte, 4)
 	var size int32
 
 	// First 4 bytes are always size of message.
-	n, err := io.ReadFull(node.conn, buf)
+	n, err = io.ReadFull(conn, buf)
 	if err != nil {
-		node.RecordError(1.0)
-		return nil, err
+		node.recordError(1.0)
+		return nil, n, err
 	}
 
 	if n == 4 {
@@ -184,18 +461,19 @@ func (node *Node) read() (respraw []byte, err error) {
 		binary.Read(sbuf, binary.BigEndian, &size)
 		data := make([]byte, size)
 		// read rest of message
-		m, err := io.ReadFull(node.conn, data)
+		m, err := io.ReadFull(conn, data)
+		n += m
 		if err != nil {
-			node.RecordError(1.0)
-			return nil, err
+			node.recordError(1.0)
+			return nil, n, err
 		}
 		if m == int(size) {
-			return data, nil // return message
+			return data, n, nil // return message
 		}
 	}
 
-	node.RecordError(1.0)
-	return nil, nil
+	node.recordError(1.0)
+	return nil, n, nil
 }
 
 func (node *Node) response() (response interface{}, err error) {
@@ -206,7 +484,7 @@ func (node *Node) response() (response interface{}, err error) {
 
 	err = validateResponseHeader(rawresp)
 	if err != nil {
-		node.RecordError(1.0)
+		node.recordError(1.0)
 		return nil, err
 	}
 
@@ -222,15 +500,23 @@ func (node *Node) response() (response interface{}, err error) {
 }
 
 func (node *Node) write(formattedRequest []byte) (err error) {
-	node.conn.SetWriteDeadline(time.Now().Add(node.writeTimeout))
+	n, err := node.writeTo(node.conn, formattedRequest)
+	node.bytesOut += n
+	return err
+}
 
-	_, err = node.conn.Write(formattedRequest)
+// writeTo writes a formatted request to conn, returning the number of bytes
+// written.
+func (node *Node) writeTo(conn *net.TCPConn, formattedRequest []byte) (n int, err error) {
+	conn.SetWriteDeadline(time.Now().Add(node.writeTimeout))
+
+	n, err = conn.Write(formattedRequest)
 	if err != nil {
-		node.RecordError(1.0)
-		return err
+		node.recordError(1.0)
+		return n, err
 	}
 
-	return nil
+	return n, nil
 }
 
 func (node *Node) request(reqstruct interface{}, structname string) (err error) {
@@ -1,7 +1,11 @@
 package riakpbc
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 )
@@ -16,71 +20,149 @@ func (c *Client) NewFetchObjectRequest(bucket, key string) *RpbGetReq {
 	}
 }
 
-func (c *Client) fetchObject(opts *RpbGetReq, bucket, key string) (*RpbGetResp, error) {
+func (c *Client) fetchObject(operation string, opts *RpbGetReq, bucket, key string) (*RpbGetResp, error) {
 	if opts == nil {
 		opts = c.NewFetchObjectRequest(bucket, key)
 	}
 
-	response, err := c.ReqResp(opts, "RpbGetReq", false)
+	response, err := c.reqResp(operation, opts, "RpbGetReq", false)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.decompress(response.(*RpbGetResp).GetContent()); err != nil {
+		return nil, err
+	}
+
 	return response.(*RpbGetResp), nil
 }
 
 // FetchObject returns an object from a bucket and returns a RpbGetResp response.
-func (c *Client) FetchObject(bucket, key string) (*RpbGetResp, error) {
-	return c.fetchObject(nil, bucket, key)
+//
+// Pass GetOptions for optional parameters such as the read quorum.
+func (c *Client) FetchObject(bucket, key string, opts ...*GetOptions) (*RpbGetResp, error) {
+	return c.fetchObject("FetchObject", c.getRequest(bucket, key, opts), bucket, key)
 }
 
-// NewStoreObjectRequest prepares a StoreObject request.
+// NewStoreObjectRequest prepares a StoreObject request. An empty key is
+// left out of the request for Riak to generate one.
 func (c *Client) NewStoreObjectRequest(bucket, key string) *RpbPutReq {
 	return &RpbPutReq{
 		Bucket: []byte(bucket),
-		Key:    []byte(key),
+		Key:    optionalKey(key),
+	}
+}
+
+// optionalKey returns key for a request, or nil when it is empty.
+func optionalKey(key string) []byte {
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// objectContent builds the content StoreObject sends for in.
+func objectContent(in interface{}) (*RpbContent, error) {
+	switch v := in.(type) {
+	case *RpbContent:
+		if v == nil {
+			return nil, errors.New("Cannot store a nil RpbContent")
+		}
+		return v, nil
+	case json.RawMessage:
+		return &RpbContent{
+			Value:       v,
+			ContentType: []byte("application/json"),
+		}, nil
+	case []byte:
+		return &RpbContent{
+			Value:       v,
+			ContentType: []byte("application/octet-stream"),
+		}, nil
+	case string:
+		return &RpbContent{
+			Value:       []byte(v),
+			ContentType: []byte("plain/text"),
+		}, nil
+	case encoding.BinaryMarshaler:
+		data, err := v.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return &RpbContent{
+			Value:       data,
+			ContentType: []byte("application/octet-stream"),
+		}, nil
+	case io.Reader:
+		data, err := io.ReadAll(v)
+		if err != nil {
+			return nil, err
+		}
+		return &RpbContent{
+			Value:       data,
+			ContentType: []byte("application/octet-stream"),
+		}, nil
+	}
+
+	// Determine the primitive type of content.
+	var value string
+	t := reflect.ValueOf(in)
+	switch t.Kind() {
+	case reflect.String:
+		value = t.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = strconv.FormatInt(t.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = strconv.FormatUint(t.Uint(), 10)
+	case reflect.Float32:
+		value = strconv.FormatFloat(t.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		value = strconv.FormatFloat(t.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		value = strconv.FormatBool(t.Bool())
+	case reflect.Slice:
+		if t.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, errors.New(fmt.Sprintf("Cannot store a %s, must be RpbContent, string, number, bool, []byte, io.Reader or encoding.BinaryMarshaler", t.Type()))
+		}
+		return &RpbContent{
+			Value:       t.Bytes(),
+			ContentType: []byte("application/octet-stream"),
+		}, nil
+	case reflect.Invalid:
+		return nil, errors.New("Cannot store nil content")
+	default:
+		return nil, errors.New(fmt.Sprintf("Cannot store a %s, must be RpbContent, string, number, bool, []byte, io.Reader or encoding.BinaryMarshaler", t.Type()))
 	}
+
+	return &RpbContent{
+		Value:       []byte(value),
+		ContentType: []byte("plain/text"),
+	}, nil
 }
 
-func (c *Client) storeObject(opts *RpbPutReq, bucket, key string, in interface{}) (*RpbPutResp, error) {
+func (c *Client) storeObject(operation string, opts *RpbPutReq, bucket, key string, in interface{}) (*RpbPutResp, error) {
 	if opts == nil {
 		opts = c.NewStoreObjectRequest(bucket, key)
 	}
 
-	if _, ok := in.(*RpbContent); ok {
-		opts.Content = in.(*RpbContent)
-	} else {
-		// Determine the primitive type of content.
-		t := reflect.TypeOf(in)
-
-		switch t.Kind() {
-		case reflect.String:
-			opts.Content = &RpbContent{
-				Value:       []byte(in.(string)),
-				ContentType: []byte("plain/text"),
-			}
-			break
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			opts.Content = &RpbContent{
-				Value:       []byte(strconv.FormatInt(int64(in.(int)), 10)),
-				ContentType: []byte("plain/text"),
-			}
-			break
-		default:
-			opts.Content = &RpbContent{
-				Value:       in.([]byte),
-				ContentType: []byte("application/octet-stream"),
-			}
-			break
-		}
+	content, err := objectContent(in)
+	if err != nil {
+		return nil, err
 	}
 
-	if opts.Content == nil {
-		return nil, errors.New("Invalid content type passed.  Must be RpbContent, string, int, or []byte")
+	content, err = c.compress(content)
+	if err != nil {
+		return nil, err
 	}
+	opts.Content = content
 
-	response, err := c.ReqResp(opts, "RpbPutReq", false)
+	response, err := c.reqResp(operation, opts, "RpbPutReq", false)
 	if err != nil {
+		return nil, putError(err)
+	}
+
+	// A returned body or head holds the content as stored
+	if err := c.decompress(response.(*RpbPutResp).GetContent()); err != nil {
 		return nil, err
 	}
 
@@ -89,11 +171,14 @@ func (c *Client) storeObject(opts *RpbPutReq, bucket, key string, in interface{}
 
 // StoreObject puts an object with key into bucket and returns a RpbGetResp response.
 //
-// The `in` content can be passed as either a RpbContent, string, int, or []byte.
+// The `in` content can be passed as either a RpbContent, string, any int,
+// uint or float type, bool, []byte, json.RawMessage, io.Reader or
+// encoding.BinaryMarshaler. Anything else is an error.
 //
 // Use RpbContent if you need absolute control over what is going into Riak.
-func (c *Client) StoreObject(bucket, key string, in interface{}) (*RpbPutResp, error) {
-	return c.storeObject(nil, bucket, key, in)
+// Pass PutOptions for optional parameters such as the write quorum.
+func (c *Client) StoreObject(bucket, key string, in interface{}, opts ...*PutOptions) (*RpbPutResp, error) {
+	return c.storeObject("StoreObject", c.putRequest(bucket, key, opts), bucket, key, in)
 }
 
 // NewDeleteObjectRequest prepares a DeleteObject request.
@@ -104,12 +189,12 @@ func (c *Client) NewDeleteObjectRequest(bucket, key string) *RpbDelReq {
 	}
 }
 
-func (c *Client) deleteObject(opts *RpbDelReq, bucket, key string) ([]byte, error) {
+func (c *Client) deleteObject(operation string, opts *RpbDelReq, bucket, key string) ([]byte, error) {
 	if opts == nil {
 		opts = c.NewDeleteObjectRequest(bucket, key)
 	}
 
-	response, err := c.ReqResp(opts, "RpbDelReq", false)
+	response, err := c.reqResp(operation, opts, "RpbDelReq", false)
 	if err != nil {
 		return nil, err
 	}
@@ -118,8 +203,93 @@ func (c *Client) deleteObject(opts *RpbDelReq, bucket, key string) ([]byte, erro
 }
 
 // DeleteObject removes object with key from bucket.
-func (c *Client) DeleteObject(bucket, key string) ([]byte, error) {
-	return c.deleteObject(nil, bucket, key)
+//
+// Pass DeleteOptions for optional parameters such as the vclock of the object.
+// Without the vclock a concurrent write the client hasn't seen may be
+// deleted, or a delete racing a write may be undone; see DeleteSafely().
+func (c *Client) DeleteObject(bucket, key string, opts ...*DeleteOptions) ([]byte, error) {
+	return c.deleteObject("DeleteObject", c.deleteRequest(bucket, key, opts), bucket, key)
+}
+
+// DeleteStruct removes the object a struct was fetched from, sending the
+// vclock of its `riak:"vclock"` field. An empty key is taken from its
+// `riak:"key"` field.
+func (c *Client) DeleteStruct(bucket, key string, in interface{}, opts ...*DeleteOptions) ([]byte, error) {
+	if _, err := structElem(in); err != nil {
+		return nil, errors.New(fmt.Sprintf("DeleteStruct: %s", err))
+	}
+	if key == "" {
+		key = structKey(in)
+	}
+
+	req := c.deleteRequest(bucket, key, opts)
+	if req.Vclock == nil {
+		req.Vclock = structVclock(in)
+	}
+	return c.deleteObject("DeleteStruct", req, bucket, key)
+}
+
+// DeleteSafely fetches the current vclock of an object, then deletes the
+// object with it, unless the options carry a vclock already. Objects which
+// don't exist or are deleted already are left alone.
+//
+// The R, PR and Timeout options apply to the fetch as well as the delete.
+func (c *Client) DeleteSafely(bucket, key string, opts ...*DeleteOptions) error {
+	req := c.deleteRequest(bucket, key, opts)
+
+	if req.Vclock == nil {
+		get := c.NewFetchObjectRequest(bucket, key)
+		get.R = req.R
+		get.Pr = req.Pr
+		get.Timeout = req.Timeout
+		get.Head = flag(true)
+		get.Deletedvclock = flag(true)
+
+		response, err := c.fetchObject("DeleteSafely", get, bucket, key)
+		if err == ErrObjectNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if IsTombstone(response) {
+			return nil
+		}
+		req.Vclock = response.GetVclock()
+	}
+
+	_, err := c.deleteObject("DeleteSafely", req, bucket, key)
+	return err
+}
+
+// IsTombstone reports whether a fetched object is deleted: it carries a
+// vclock, as fetched with GetOptions.Deleted, but no live content.
+func IsTombstone(response *RpbGetResp) bool {
+	if len(response.GetVclock()) == 0 {
+		return false
+	}
+	for _, content := range response.GetContent() {
+		if !content.GetDeleted() {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchTombstone fetches an object like FetchObject, but returns deleted
+// objects along with their vclock rather than ErrObjectNotFound, deleted
+// reporting which it is. The vclock lets a write or delete follow the
+// deletion rather than race it.
+func (c *Client) FetchTombstone(bucket, key string, opts ...*GetOptions) (response *RpbGetResp, deleted bool, err error) {
+	req := c.getRequest(bucket, key, opts)
+	req.Deletedvclock = flag(true)
+
+	response, err = c.fetchObject("FetchTombstone", req, bucket, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return response, IsTombstone(response), nil
 }
 
 // NewFetchStructRequest prepares a FetchStruct request.
@@ -130,91 +300,99 @@ func (c *Client) NewFetchStructRequest(bucket, key string) *RpbGetReq {
 	}
 }
 
-func (c *Client) fetchStruct(opts *RpbGetReq, bucket, key string, out interface{}) (*RpbGetResp, error) {
-	if c.Coder == nil {
-		panic("Cannot fetch to a struct unless a coder has been set")
+func (c *Client) fetchStruct(operation string, opts *RpbGetReq, bucket, key string, out interface{}) (*RpbGetResp, error) {
+	if _, err := structElem(out); err != nil {
+		return &RpbGetResp{}, errors.New(fmt.Sprintf("FetchStruct: %s", err))
 	}
 
 	if opts == nil {
 		opts = c.NewFetchStructRequest(bucket, key)
 	}
 
-	response, err := c.ReqResp(opts, "RpbGetReq", false)
+	response, err := c.reqResp(operation, opts, "RpbGetReq", false)
 	if err != nil {
 		return &RpbGetResp{}, err
 	}
 
-	t := reflect.TypeOf(out)
-	if t.Kind() == reflect.Ptr { // struct
-		switch t.Elem().Kind() {
-		// Structs get passed through a marshaller
-		case reflect.Struct:
-			// TODO: This only returns the first result.
-			//  I believe the other possible results are related to vlocks, and will eventually need to be addressed.
-			err := c.Coder.Unmarshal(response.(*RpbGetResp).GetContent()[0].GetValue(), out)
-			if err != nil {
-				return &RpbGetResp{}, err
-			}
-		default:
-			panic("Invalid out struct type passed to FetchStruct")
-		}
+	if err := c.decompress(response.(*RpbGetResp).GetContent()); err != nil {
+		return &RpbGetResp{}, err
+	}
+
+	// TODO: This only returns the first result.
+	//  I believe the other possible results are related to vlocks, and will eventually need to be addressed.
+	if len(response.(*RpbGetResp).GetContent()) == 0 {
+		return &RpbGetResp{}, ErrNoContent
+	}
+	content := response.(*RpbGetResp).GetContent()[0]
+
+	// Structs get passed through a marshaller
+	coder := c.CoderFor(string(content.GetContentType()))
+	if coder == nil {
+		return &RpbGetResp{}, ErrNoCoder
+	}
+	if err := coder.UnmarshalContent(content, out); err != nil {
+		return &RpbGetResp{}, err
 	}
+	setStructObject(out, key, response.(*RpbGetResp).GetVclock())
 
 	return response.(*RpbGetResp), nil
 }
 
 // FetchStruct returns an object from a bucket and unmarshals it into the passed struct.
 //
-// Pass RpbGetReq to SetOpts for optional parameters.
-func (c *Client) FetchStruct(bucket, key string, out interface{}) (*RpbGetResp, error) {
-	return c.fetchStruct(nil, bucket, key, out)
+// Pass GetOptions for optional parameters.
+func (c *Client) FetchStruct(bucket, key string, out interface{}, opts ...*GetOptions) (*RpbGetResp, error) {
+	return c.fetchStruct("FetchStruct", c.getRequest(bucket, key, opts), bucket, key, out)
 }
 
-// NewStoreStructRequest prepares a StoreStruct request.
+// NewStoreStructRequest prepares a StoreStruct request. An empty key is
+// left out of the request for Riak to generate one.
 func (c *Client) NewStoreStructRequest(bucket, key string) *RpbPutReq {
 	return &RpbPutReq{
 		Bucket: []byte(bucket),
-		Key:    []byte(key),
+		Key:    optionalKey(key),
 	}
 }
 
-func (c *Client) storeStruct(opts *RpbPutReq, bucket, key string, in interface{}) (*RpbPutResp, error) {
-	if c.Coder == nil {
-		panic("Cannot store a struct unless a coder has been set")
-	}
-
+func (c *Client) storeStruct(operation string, opts *RpbPutReq, bucket, key string, in interface{}) (*RpbPutResp, error) {
 	if opts == nil {
 		opts = c.NewStoreStructRequest(bucket, key)
 	}
 
-	if _, ok := in.(*RpbContent); ok {
-		opts.Content = in.(*RpbContent)
-	} else {
-		// Determine the primitive type of content.
-		t := reflect.TypeOf(in)
-
-		if t.Kind() == reflect.Ptr { // struct or RpbContent
-			switch t.Elem().Kind() {
-			case reflect.Struct:
-				// Structs get passed through a marshaller
-				encctnt, err := c.Coder.Marshal(in)
-				if err != nil {
-					return nil, err
-				}
-				opts.Content = encctnt
-				break
-			default:
-				panic("Invalid in struct type passed to StoreStruct")
-			}
+	var content *RpbContent
+	if raw, ok := in.(*RpbContent); ok && raw != nil {
+		content = raw
+	} else if _, err := structElem(in); err == nil {
+		// Structs get passed through a marshaller
+		if c.Coder == nil {
+			return nil, ErrNoCoder
 		}
+		if content, err = c.Coder.Marshal(in); err != nil {
+			return nil, err
+		}
+		if len(opts.Key) == 0 {
+			opts.Key = optionalKey(structKey(in))
+		}
+		if opts.Vclock == nil {
+			opts.Vclock = structVclock(in)
+		}
+	} else if content, err = objectContent(in); err != nil {
+		return nil, errors.New(fmt.Sprintf("StoreStruct: %s", err))
 	}
 
-	if opts.Content == nil {
-		return nil, errors.New("Invalid content type passed.  Must be struct, RpbContent, string, int, or []byte")
+	content, err := c.compress(content)
+	if err != nil {
+		return nil, err
 	}
+	opts.Content = content
 
-	response, err := c.ReqResp(opts, "RpbPutReq", false)
+	response, err := c.reqResp(operation, opts, "RpbPutReq", false)
 	if err != nil {
+		return nil, putError(err)
+	}
+
+	// A returned body or head holds the content as stored
+	if err := c.decompress(response.(*RpbPutResp).GetContent()); err != nil {
 		return nil, err
 	}
 
@@ -224,6 +402,57 @@ func (c *Client) storeStruct(opts *RpbPutReq, bucket, key string, in interface{}
 // StoreStruct marshals the data from a struct, and adds it with key into bucket.
 //
 // Check Coder.Marshall() for `riak` tags that can be set on a structure for automated indexes and links.
-func (c *Client) StoreStruct(bucket, key string, in interface{}) (*RpbPutResp, error) {
-	return c.storeStruct(nil, bucket, key, in)
+// Pass PutOptions for optional parameters.
+func (c *Client) StoreStruct(bucket, key string, in interface{}, opts ...*PutOptions) (*RpbPutResp, error) {
+	return c.storeStruct("StoreStruct", c.putRequest(bucket, key, opts), bucket, key, in)
+}
+
+// Create stores in under a key generated by Riak, and returns that key.
+//
+// The `in` content can be passed as anything StoreObject accepts.
+func (c *Client) Create(bucket string, in interface{}, opts ...*PutOptions) (string, error) {
+	req := c.putRequest(bucket, "", opts)
+	req.Key = nil
+
+	response, err := c.storeObject("Create", req, bucket, "", in)
+	if err != nil {
+		return "", err
+	}
+	if len(response.GetKey()) == 0 {
+		return "", errors.New("Riak did not return a generated key")
+	}
+
+	return string(response.GetKey()), nil
+}
+
+// CreateStruct marshals the data from a struct and stores it under a key
+// generated by Riak, ignoring any `riak:"key"` field. That field, and the
+// `riak:"vclock"` field, are filled from the response; the key is returned
+// too.
+func (c *Client) CreateStruct(bucket string, in interface{}, opts ...*PutOptions) (string, error) {
+	if _, err := structElem(in); err != nil {
+		return "", errors.New(fmt.Sprintf("CreateStruct: %s", err))
+	}
+	if c.Coder == nil {
+		return "", ErrNoCoder
+	}
+
+	content, err := c.Coder.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+
+	req := c.putRequest(bucket, "", opts)
+	req.Key = nil
+
+	response, err := c.storeObject("CreateStruct", req, bucket, "", content)
+	if err != nil {
+		return "", err
+	}
+	if len(response.GetKey()) == 0 {
+		return "", errors.New("Riak did not return a generated key")
+	}
+
+	setStructObject(in, string(response.GetKey()), response.GetVclock())
+	return string(response.GetKey()), nil
 }
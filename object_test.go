@@ -1,10 +1,13 @@
 package riakpbc
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/bmizerany/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 type Data struct {
@@ -394,3 +397,257 @@ func TestDeleteObjectDo(t *testing.T) {
 
 	teardownData(t, riak)
 }
+
+type count int16
+
+func TestObjectContent(t *testing.T) {
+	stamp := time.Date(2013, 8, 3, 0, 0, 0, 0, time.UTC)
+	binaryStamp, _ := stamp.MarshalBinary()
+
+	tests := []struct {
+		in          interface{}
+		value       []byte
+		contentType string
+	}{
+		{&RpbContent{Value: []byte("raw"), ContentType: []byte("text/html")}, []byte("raw"), "text/html"},
+		{"string data", []byte("string data"), "plain/text"},
+		{1000, []byte("1000"), "plain/text"},
+		{int8(-8), []byte("-8"), "plain/text"},
+		{int16(16), []byte("16"), "plain/text"},
+		{int32(32), []byte("32"), "plain/text"},
+		{int64(1) << 40, []byte("1099511627776"), "plain/text"},
+		{uint(7), []byte("7"), "plain/text"},
+		{uint64(1) << 63, []byte("9223372036854775808"), "plain/text"},
+		{count(12), []byte("12"), "plain/text"},
+		{float32(1.5), []byte("1.5"), "plain/text"},
+		{3.25, []byte("3.25"), "plain/text"},
+		{true, []byte("true"), "plain/text"},
+		{[]byte("binary data"), []byte("binary data"), "application/octet-stream"},
+		{json.RawMessage(`{"a":1}`), []byte(`{"a":1}`), "application/json"},
+		{bytes.NewBufferString("reader data"), []byte("reader data"), "application/octet-stream"},
+		{stamp, binaryStamp, "application/octet-stream"},
+	}
+
+	for _, test := range tests {
+		content, err := objectContent(test.in)
+		if err != nil {
+			t.Errorf("%T: %s", test.in, err)
+			continue
+		}
+		if !bytes.Equal(content.GetValue(), test.value) {
+			t.Errorf("%T: value %q, expected %q", test.in, content.GetValue(), test.value)
+		}
+		if string(content.GetContentType()) != test.contentType {
+			t.Errorf("%T: content type %q, expected %q", test.in, content.GetContentType(), test.contentType)
+		}
+	}
+
+	invalid := []interface{}{
+		nil,
+		(*RpbContent)(nil),
+		[]string{"a"},
+		map[string]string{},
+		&Data{},
+		complex(1, 2),
+	}
+
+	for _, in := range invalid {
+		if _, err := objectContent(in); err == nil {
+			t.Errorf("%T: expected an error", in)
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	// No coder
+	_, err := riak.StoreStruct("bucket", "key", &Data{Data: "data"})
+	assert.Equal(t, ErrNoCoder, err)
+
+	_, err = riak.StoreObject("bucket", "key", "plain")
+	assert.T(t, err == nil)
+	_, err = riak.FetchStruct("bucket", "key", &Data{})
+	assert.Equal(t, ErrNoCoder, err)
+
+	riak.Coder = NewJsonCoder()
+
+	tests := []struct {
+		name string
+		err  func() error
+	}{
+		{"fetch into a non pointer", func() error {
+			_, err := riak.FetchStruct("bucket", "key", Data{})
+			return err
+		}},
+		{"fetch into a non struct", func() error {
+			out := ""
+			_, err := riak.FetchStruct("bucket", "key", &out)
+			return err
+		}},
+		{"fetch into nil", func() error {
+			_, err := riak.FetchStruct("bucket", "key", nil)
+			return err
+		}},
+		{"store a pointer to a non struct", func() error {
+			in := 1
+			_, err := riak.StoreStruct("bucket", "key", &in)
+			return err
+		}},
+		{"store an unsupported type", func() error {
+			_, err := riak.StoreObject("bucket", "key", []int{1})
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		if test.err() == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	// Non structs are stored as objects
+	_, err = riak.StoreStruct("bucket", "key", 42)
+	assert.T(t, err == nil)
+
+	_, err = riak.StoreStruct("bucket", "key", &Data{Data: "data"})
+	assert.T(t, err == nil)
+	out := &Data{}
+	_, err = riak.FetchStruct("bucket", "key", out)
+	assert.T(t, err == nil)
+	assert.Equal(t, "data", out.Data)
+}
+
+func TestCreate(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	key, err := riak.Create("bucket", "created")
+	assert.T(t, err == nil)
+	assert.T(t, key != "")
+	assert.T(t, server.object("bucket", key) != nil)
+
+	req := &RpbPutReq{}
+	server.lastRequest("RpbPutReq", req)
+	assert.T(t, req.Key == nil)
+
+	type Keyed struct {
+		Key    string `json:"-" riak:"key"`
+		Vclock []byte `json:"-" riak:"vclock"`
+		Data   string `json:"data"`
+	}
+
+	in := &Keyed{Key: "ignored", Data: "data"}
+	key, err = riak.CreateStruct("bucket", in)
+	assert.T(t, err == nil)
+	assert.Equal(t, key, in.Key)
+	assert.T(t, len(in.Vclock) > 0)
+	assert.T(t, server.object("bucket", "ignored") == nil)
+
+	out := &Keyed{}
+	_, err = riak.FetchStruct("bucket", key, out)
+	assert.T(t, err == nil)
+	assert.Equal(t, "data", out.Data)
+	assert.Equal(t, key, out.Key)
+
+	// StoreObject with an empty key generates one too
+	resp, err := riak.StoreObject("bucket", "", "stored")
+	assert.T(t, err == nil)
+	assert.T(t, len(resp.GetKey()) > 0)
+
+	_, err = riak.CreateStruct("bucket", "not a struct")
+	assert.T(t, err != nil)
+}
+
+func TestTombstones(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	_, err := riak.StoreObject("bucket", "key", "value")
+	assert.T(t, err == nil)
+
+	obj, deleted, err := riak.FetchTombstone("bucket", "key")
+	assert.T(t, err == nil)
+	assert.T(t, !deleted)
+	assert.Equal(t, "value", string(obj.GetContent()[0].GetValue()))
+
+	assert.T(t, riak.DeleteSafely("bucket", "key") == nil)
+	del := &RpbDelReq{}
+	server.lastRequest("RpbDelReq", del)
+	assert.Equal(t, obj.GetVclock(), del.GetVclock())
+
+	_, err = riak.FetchObject("bucket", "key")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	obj, deleted, err = riak.FetchTombstone("bucket", "key")
+	assert.T(t, err == nil)
+	assert.T(t, deleted)
+	assert.T(t, len(obj.GetVclock()) > 0)
+
+	meta, err := riak.FetchMeta("bucket", "key", &GetOptions{Deleted: true})
+	assert.T(t, err == nil)
+	assert.T(t, meta.Deleted)
+	assert.Equal(t, obj.GetVclock(), meta.Vclock)
+
+	exists, err := riak.Exists("bucket", "key", &GetOptions{Deleted: true})
+	assert.T(t, err == nil)
+	assert.T(t, !exists)
+
+	// Deleting again, or deleting what never existed, sends nothing
+	server.Lock()
+	delete(server.requests, "RpbDelReq")
+	server.Unlock()
+	assert.T(t, riak.DeleteSafely("bucket", "key") == nil)
+	assert.T(t, riak.DeleteSafely("bucket", "missing") == nil)
+	server.Lock()
+	_, sent := server.requests["RpbDelReq"]
+	server.Unlock()
+	assert.T(t, !sent)
+
+	_, _, err = riak.FetchTombstone("bucket", "missing")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestDeleteStruct(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	type Versioned struct {
+		Key    string `json:"-" riak:"key"`
+		Vclock []byte `json:"-" riak:"vclock"`
+		Data   string `json:"data"`
+	}
+
+	_, err := riak.StoreStruct("bucket", "key", &Versioned{Data: "data"})
+	assert.T(t, err == nil)
+
+	v := &Versioned{}
+	_, err = riak.FetchStruct("bucket", "key", v, &GetOptions{R: QuorumAll})
+	assert.T(t, err == nil)
+
+	_, err = riak.DeleteStruct("bucket", "", v, &DeleteOptions{RW: QuorumAll})
+	assert.T(t, err == nil)
+
+	del := &RpbDelReq{}
+	server.lastRequest("RpbDelReq", del)
+	assert.Equal(t, "key", string(del.GetKey()))
+	assert.Equal(t, v.Vclock, del.GetVclock())
+	assert.Equal(t, QuorumAll, del.GetRw())
+	assert.T(t, server.object("bucket", "key") == nil)
+}
@@ -0,0 +1,155 @@
+package riakpbc
+
+import (
+	"math"
+	"time"
+)
+
+// Symbolic quorum values, usable for any of the R, PR, W, DW, PW and RW
+// options in place of a number of replicas. Zero leaves a quorum unset.
+const (
+	QuorumOne      uint32 = math.MaxUint32 - 1 // a single replica
+	QuorumMajority uint32 = math.MaxUint32 - 2 // a majority of the replicas
+	QuorumAll      uint32 = math.MaxUint32 - 3 // every replica
+	QuorumDefault  uint32 = math.MaxUint32 - 4 // the bucket's setting
+)
+
+// timeoutMillis converts a timeout to the milliseconds Riak expects, or nil
+// when the timeout is not set.
+func timeoutMillis(timeout time.Duration) *uint32 {
+	if timeout <= 0 {
+		return nil
+	}
+	ms := uint32(timeout / time.Millisecond)
+	return &ms
+}
+
+// quorum returns a quorum value for a request, or nil when it is not set.
+func quorum(q uint32) *uint32 {
+	if q == 0 {
+		return nil
+	}
+	return &q
+}
+
+// flag returns a bool for a request, or nil when it is not set.
+func flag(b bool) *bool {
+	if !b {
+		return nil
+	}
+	return &b
+}
+
+// GetOptions are the optional parameters of FetchObject and FetchStruct.
+type GetOptions struct {
+	R           uint32 // replicas to read from
+	PR          uint32 // primary replicas to read from
+	BasicQuorum *bool  // return early once a majority of replicas fail; nil for the bucket's setting
+	NotFoundOk  *bool  // count not found as a successful read; nil for the bucket's setting
+	Head        bool   // return the metadata of the object only
+	Deleted     bool   // return the vclock of a deleted object
+	Timeout     time.Duration
+}
+
+// apply sets the options on req.
+func (opts *GetOptions) apply(req *RpbGetReq) {
+	if opts == nil {
+		return
+	}
+	req.R = quorum(opts.R)
+	req.Pr = quorum(opts.PR)
+	req.BasicQuorum = opts.BasicQuorum
+	req.NotfoundOk = opts.NotFoundOk
+	req.Head = flag(opts.Head)
+	req.Deletedvclock = flag(opts.Deleted)
+	req.Timeout = timeoutMillis(opts.Timeout)
+}
+
+// PutOptions are the optional parameters of StoreObject and StoreStruct.
+type PutOptions struct {
+	Vclock        []byte // the vclock of the object being replaced
+	W             uint32 // replicas to write to
+	DW            uint32 // replicas to durably write to
+	PW            uint32 // primary replicas to write to
+	ReturnBody    bool   // return the stored object
+	ReturnHead    bool   // return the metadata of the stored object
+	IfNotModified bool   // only store if Vclock is the object's current vclock
+	IfNoneMatch   bool   // only store if the key doesn't exist
+	Asis          bool   // store the object without the coordinating vnode updating its vclock
+	Timeout       time.Duration
+}
+
+// apply sets the options on req.
+func (opts *PutOptions) apply(req *RpbPutReq) {
+	if opts == nil {
+		return
+	}
+	if opts.Vclock != nil {
+		req.Vclock = opts.Vclock
+	}
+	req.W = quorum(opts.W)
+	req.Dw = quorum(opts.DW)
+	req.Pw = quorum(opts.PW)
+	req.ReturnBody = flag(opts.ReturnBody)
+	req.ReturnHead = flag(opts.ReturnHead)
+	req.IfNotModified = flag(opts.IfNotModified)
+	req.IfNoneMatch = flag(opts.IfNoneMatch)
+	req.Asis = flag(opts.Asis)
+	req.Timeout = timeoutMillis(opts.Timeout)
+}
+
+// DeleteOptions are the optional parameters of DeleteObject.
+type DeleteOptions struct {
+	Vclock  []byte // the vclock of the object being deleted
+	RW      uint32 // replicas to read from and delete on
+	R       uint32
+	W       uint32
+	PR      uint32
+	PW      uint32
+	DW      uint32
+	Timeout time.Duration
+}
+
+// apply sets the options on req.
+func (opts *DeleteOptions) apply(req *RpbDelReq) {
+	if opts == nil {
+		return
+	}
+	if opts.Vclock != nil {
+		req.Vclock = opts.Vclock
+	}
+	req.Rw = quorum(opts.RW)
+	req.R = quorum(opts.R)
+	req.W = quorum(opts.W)
+	req.Pr = quorum(opts.PR)
+	req.Pw = quorum(opts.PW)
+	req.Dw = quorum(opts.DW)
+	req.Timeout = timeoutMillis(opts.Timeout)
+}
+
+// getRequest prepares a RpbGetReq with opts applied in order.
+func (c *Client) getRequest(bucket, key string, opts []*GetOptions) *RpbGetReq {
+	req := c.NewFetchObjectRequest(bucket, key)
+	for _, o := range opts {
+		o.apply(req)
+	}
+	return req
+}
+
+// putRequest prepares a RpbPutReq with opts applied in order.
+func (c *Client) putRequest(bucket, key string, opts []*PutOptions) *RpbPutReq {
+	req := c.NewStoreObjectRequest(bucket, key)
+	for _, o := range opts {
+		o.apply(req)
+	}
+	return req
+}
+
+// deleteRequest prepares a RpbDelReq with opts applied in order.
+func (c *Client) deleteRequest(bucket, key string, opts []*DeleteOptions) *RpbDelReq {
+	req := c.NewDeleteObjectRequest(bucket, key)
+	for _, o := range opts {
+		o.apply(req)
+	}
+	return req
+}
@@ -0,0 +1,76 @@
+package riakpbc
+
+import (
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	put := &RpbPutReq{}
+	_, err := riak.StoreObject("bucket", "key", "data", &PutOptions{
+		Vclock:     []byte("vclock"),
+		W:          QuorumAll,
+		DW:         2,
+		ReturnBody: true,
+		Timeout:    time.Second,
+	})
+	assert.T(t, err == nil)
+	server.lastRequest("RpbPutReq", put)
+	assert.Equal(t, []byte("vclock"), put.GetVclock())
+	assert.Equal(t, QuorumAll, put.GetW())
+	assert.Equal(t, uint32(2), put.GetDw())
+	assert.T(t, put.Pw == nil)
+	assert.T(t, put.GetReturnBody())
+	assert.T(t, put.IfNoneMatch == nil)
+	assert.Equal(t, uint32(1000), put.GetTimeout())
+
+	get := &RpbGetReq{}
+	_, err = riak.FetchObject("bucket", "key", &GetOptions{
+		R:          QuorumOne,
+		PR:         QuorumDefault,
+		NotFoundOk: proto.Bool(false),
+		Deleted:    true,
+	})
+	assert.T(t, err == nil)
+	server.lastRequest("RpbGetReq", get)
+	assert.Equal(t, QuorumOne, get.GetR())
+	assert.Equal(t, QuorumDefault, get.GetPr())
+	assert.T(t, get.NotfoundOk != nil && !get.GetNotfoundOk())
+	assert.T(t, get.BasicQuorum == nil)
+	assert.T(t, get.GetDeletedvclock())
+	assert.T(t, get.Head == nil)
+	assert.T(t, get.Timeout == nil)
+
+	// Struct variants take the same options
+	_, err = riak.StoreStruct("bucket", "struct", &Data{Data: "data"}, &PutOptions{IfNoneMatch: true})
+	assert.T(t, err == nil)
+	server.lastRequest("RpbPutReq", put)
+	assert.T(t, put.GetIfNoneMatch())
+
+	_, err = riak.FetchStruct("bucket", "struct", &Data{}, &GetOptions{R: 1})
+	assert.T(t, err == nil)
+	server.lastRequest("RpbGetReq", get)
+	assert.Equal(t, uint32(1), get.GetR())
+
+	del := &RpbDelReq{}
+	_, err = riak.DeleteObject("bucket", "key", &DeleteOptions{Vclock: []byte("vclock"), RW: QuorumMajority})
+	assert.T(t, err == nil)
+	server.lastRequest("RpbDelReq", del)
+	assert.Equal(t, []byte("vclock"), del.GetVclock())
+	assert.Equal(t, QuorumMajority, del.GetRw())
+
+	// Without options nothing optional is sent
+	_, err = riak.FetchObject("bucket", "struct")
+	assert.T(t, err == nil)
+	server.lastRequest("RpbGetReq", get)
+	assert.T(t, get.R == nil)
+}
@@ -0,0 +1,215 @@
+package riakpbc
+
+import (
+	"github.com/golang/protobuf/proto"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pipeline writes requests back to back on a single connection and matches
+// the responses to them in FIFO order, see *Node.EnablePipelining().
+type pipeline struct {
+	node    *Node
+	slots   chan struct{} // bounds the number of requests in flight
+	current *pipeConn
+	open    atomic.Bool // whether current is set, read without the lock
+	sync.Mutex
+}
+
+// pipeConn is one connection of a pipeline along with the requests awaiting
+// a response on it, oldest first.
+type pipeConn struct {
+	conn  *net.TCPConn
+	queue chan *pipeCall
+}
+
+type pipeCall struct {
+	structname string
+	begin      time.Time
+	bytesOut   int
+	done       chan pipeResult
+}
+
+type pipeResult struct {
+	response interface{}
+	size     int
+	err      error
+}
+
+func newPipeline(node *Node, depth int) *pipeline {
+	if depth < 1 {
+		depth = 1
+	}
+
+	return &pipeline{
+		node:  node,
+		slots: make(chan struct{}, depth),
+	}
+}
+
+func (p *pipeline) reqResp(reqstruct interface{}, structname string, raw bool) (response interface{}, size int, err error) {
+	var marshaledRequest []byte
+	if raw == true {
+		marshaledRequest = reqstruct.([]byte)
+	} else {
+		marshaledRequest, err = proto.Marshal(reqstruct.(proto.Message))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	formattedRequest, err := prependRequestHeader(structname, marshaledRequest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p.slots <- struct{}{}
+	defer func() {
+		<-p.slots
+	}()
+
+	call := &pipeCall{
+		structname: structname,
+		begin:      time.Now(),
+		done:       make(chan pipeResult, 1),
+	}
+
+	err = p.send(call, formattedRequest)
+	p.node.fireStateChanges()
+	if err != nil {
+		p.node.finish(structname, time.Since(call.begin), call.bytesOut, 0, err)
+		return nil, 0, err
+	}
+
+	result := <-call.done
+	return result.response, result.size, result.err
+}
+
+// send writes the request and queues the call for its response, dialing a
+// new connection if there is none.
+func (p *pipeline) send(call *pipeCall, formattedRequest []byte) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.current == nil {
+		conn, err := net.DialTCP("tcp", nil, p.node.tcpAddr)
+		if err != nil {
+			p.node.recordError(1.0)
+			p.node.log().Warn("pipeline dial failed", "node", p.node.addr, "error", err)
+			return err
+		}
+		conn.SetKeepAlive(true)
+		p.node.log().Debug("pipeline dialed", "node", p.node.addr)
+
+		p.current = &pipeConn{
+			conn:  conn,
+			queue: make(chan *pipeCall, cap(p.slots)),
+		}
+		p.open.Store(true)
+		go p.readLoop(p.current)
+	}
+
+	n, err := p.node.writeTo(p.current.conn, formattedRequest)
+	call.bytesOut = n
+	if err != nil {
+		p.retire(p.current)
+		return err
+	}
+
+	p.current.queue <- call
+	return nil
+}
+
+// readLoop delivers responses to the calls queued on pc in order. Once the
+// stream breaks, the connection is retired and every call still queued on it
+// fails with the same error.
+func (p *pipeline) readLoop(pc *pipeConn) {
+	var broken error
+
+	for call := range pc.queue {
+		if broken != nil {
+			p.deliver(call, nil, 0, broken)
+			continue
+		}
+
+		response, size, ok, err := p.read(pc.conn)
+		if !ok {
+			broken = err
+			p.Lock()
+			p.retire(pc)
+			p.Unlock()
+		}
+		p.deliver(call, response, size, err)
+		p.node.fireStateChanges()
+	}
+}
+
+// read reads the next response on conn. ok is false if the stream can no
+// longer be trusted to be at a message boundary.
+func (p *pipeline) read(conn *net.TCPConn) (response interface{}, size int, ok bool, err error) {
+	rawresp, size, err := p.node.readFrom(conn)
+	if err != nil {
+		return nil, size, false, err
+	}
+
+	err = validateResponseHeader(rawresp)
+	if err != nil {
+		p.node.recordError(1.0)
+		return nil, size, false, err
+	}
+
+	response, err = unmarshalResponse(rawresp)
+	return response, size, true, err
+}
+
+func (p *pipeline) deliver(call *pipeCall, response interface{}, size int, err error) {
+	if err == nil {
+		p.node.recordSuccess()
+	}
+	p.node.finish(call.structname, time.Since(call.begin), call.bytesOut, size, err)
+	call.done <- pipeResult{response, size, err}
+}
+
+// retire closes pc if it is still the current connection, which makes its
+// read loop fail the calls waiting on it. The pipeline must be locked.
+func (p *pipeline) retire(pc *pipeConn) {
+	if pc == nil || p.current != pc {
+		return
+	}
+
+	p.current = nil
+	p.open.Store(false)
+	pc.conn.Close()
+	close(pc.queue)
+	p.node.log().Debug("pipeline connection closed", "node", p.node.addr)
+}
+
+// close retires the current connection, if any. The next request dials anew.
+func (p *pipeline) close() {
+	p.Lock()
+	p.retire(p.current)
+	p.Unlock()
+}
+
+// EnablePipelining switches ReqResp to pipelined mode: requests are written
+// back to back on a dedicated connection, without waiting for the previous
+// response, and the responses are matched to them in order. At most depth
+// requests are in flight at once. If the stream breaks, every outstanding
+// request fails and the next one dials a new connection.
+//
+// ReqMultiResp, used by ListKeys and MapReduce, keeps using the node's
+// regular connection.
+func (node *Node) EnablePipelining(depth int) {
+	if old := node.pipe.Swap(newPipeline(node, depth)); old != nil {
+		old.close()
+	}
+}
+
+// DisablePipelining switches ReqResp back to one request per round trip.
+func (node *Node) DisablePipelining() {
+	if old := node.pipe.Swap(nil); old != nil {
+		old.close()
+	}
+}
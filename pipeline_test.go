@@ -0,0 +1,285 @@
+package riakpbc
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHandler answers a single request on a fake Riak node. It returns the
+// response message name and body; a nil body sends an empty message, and
+// testMessages send several messages of that name.
+type testHandler func(structname string, body []byte) (string, proto.Message)
+
+// testMessages is a response made of several messages, such as a streamed
+// MapReduce response.
+type testMessages []proto.Message
+
+func (m testMessages) Reset()         {}
+func (m testMessages) String() string { return "testMessages" }
+func (m testMessages) ProtoMessage()  {}
+
+// newTestServer starts a fake Riak node on a local port, answering requests
+// with handler, and returns its address.
+func newTestServer(t *testing.T, handler testHandler) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, handler)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestConn(conn net.Conn, handler testHandler) {
+	defer conn.Close()
+
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+
+		structname, resp := handler(numToCommand[int(msg[0])], msg[1:])
+		if structname == "" {
+			return
+		}
+
+		messages, ok := resp.(testMessages)
+		if !ok {
+			messages = testMessages{resp}
+		}
+
+		for _, message := range messages {
+			if err := writeTestMessage(conn, structname, message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeTestMessage(conn net.Conn, structname string, message proto.Message) error {
+	var body []byte
+	if message != nil {
+		body, _ = proto.Marshal(message)
+	}
+	formatted := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(formatted, uint32(len(body)+1))
+	formatted[4] = commandToNum[structname]
+	_, err := conn.Write(append(formatted, body...))
+	return err
+}
+
+func TestPipelinedRequests(t *testing.T) {
+	const depth = 4
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server reads requests as they arrive but holds back the first
+	// response until depth requests are in, or a second has passed
+	seen := make(chan int, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		requests := make(chan *RpbGetReq, 16)
+		go func() {
+			defer close(requests)
+			for {
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				msg := make([]byte, binary.BigEndian.Uint32(header))
+				if _, err := io.ReadFull(conn, msg); err != nil {
+					return
+				}
+				req := &RpbGetReq{}
+				proto.Unmarshal(msg[1:], req)
+				requests <- req
+			}
+		}()
+
+		var queued []*RpbGetReq
+		timeout := time.After(time.Second)
+	wait:
+		for len(queued) < depth {
+			select {
+			case req, ok := <-requests:
+				if !ok {
+					return
+				}
+				queued = append(queued, req)
+			case <-timeout:
+				break wait
+			}
+		}
+		seen <- len(queued)
+
+		respond := func(req *RpbGetReq) bool {
+			resp := &RpbGetResp{Content: []*RpbContent{{Value: req.GetKey()}}}
+			return writeTestMessage(conn, "RpbGetResp", resp) == nil
+		}
+		for _, req := range queued {
+			if !respond(req) {
+				return
+			}
+		}
+		for req := range requests {
+			if !respond(req) {
+				return
+			}
+		}
+	}()
+
+	node, err := NewNode(listener.Addr().String(), NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.EnablePipelining(depth)
+	defer node.Close()
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	errs := make(chan error, len(keys))
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			resp, err := node.ReqResp(&RpbGetReq{Bucket: []byte("bucket"), Key: []byte(key)}, "RpbGetReq", false)
+			if err == nil && string(resp.(*RpbGetResp).GetContent()[0].GetValue()) != key {
+				err = errors.New("response for another key than " + key)
+			}
+			errs <- err
+		}(key)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.T(t, err == nil)
+	}
+	assert.Equal(t, depth, <-seen)
+}
+
+func TestPipelineFailsOutstandingRequests(t *testing.T) {
+	var lock sync.Mutex
+	received := 0
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		lock.Lock()
+		defer lock.Unlock()
+		received++
+		if received == 1 {
+			return "RpbPingResp", nil
+		}
+		return "", nil
+	})
+
+	node, err := NewNode(addr, NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.EnablePipelining(4)
+
+	assert.T(t, node.Ping())
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := node.ReqResp([]byte{}, "RpbPingReq", true)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		assert.T(t, <-errs != nil)
+	}
+}
+
+func TestSerialFailureKeepsPipeline(t *testing.T) {
+	received := make(chan struct{}, 8)
+	release := make(chan struct{})
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		if structname == "RpbListKeysReq" {
+			return "", nil
+		}
+		received <- struct{}{}
+		<-release
+		return "RpbPingResp", nil
+	})
+
+	node, err := NewNode(addr, NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	node.EnablePipelining(4)
+	defer node.Close()
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := node.ReqResp([]byte{}, "RpbPingReq", true)
+			errs <- err
+		}()
+	}
+	<-received
+
+	// A request failing on the node's own connection leaves the pipelined
+	// requests in flight alone
+	_, err = node.ReqMultiResp(&RpbListKeysReq{Bucket: []byte("bucket")}, "RpbListKeysReq")
+	assert.T(t, err != nil)
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		assert.T(t, <-errs == nil)
+	}
+}
+
+func TestNodeConnections(t *testing.T) {
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		return "RpbPingResp", nil
+	})
+
+	node, err := NewNode(addr, NODE_READ_RETRY, NODE_WRITE_RETRY)
+	assert.T(t, err == nil)
+	defer node.Close()
+	assert.Equal(t, 0, node.Connections())
+
+	assert.T(t, node.Ping())
+	assert.Equal(t, 1, node.Connections())
+
+	// The pipelined connection counts along with the node's own
+	node.EnablePipelining(4)
+	assert.T(t, node.Ping())
+	assert.Equal(t, 2, node.Connections())
+
+	node.DisablePipelining()
+	assert.Equal(t, 1, node.Connections())
+
+	node.Lock()
+	node.Close()
+	node.Unlock()
+	assert.Equal(t, 0, node.Connections())
+}
@@ -8,13 +8,18 @@ import (
 )
 
 const (
-	NODE_WRITE_RETRY     time.Duration = time.Second * 10 // 10s
-	NODE_READ_RETRY      time.Duration = time.Second * 10 // 10s
-	NODE_ERROR_THRESHOLD float64       = 0.5
+	NODE_WRITE_RETRY        time.Duration = time.Second * 10 // 10s
+	NODE_READ_RETRY         time.Duration = time.Second * 10 // 10s
+	NODE_ERROR_THRESHOLD    float64       = 0.5
+	NODE_DEGRADED_THRESHOLD float64       = 0.1
+	NODE_OPEN_TIMEOUT       time.Duration = time.Second * 5 // 5s
+	NODE_HALF_OPEN_PROBES   int           = 1
+	NODE_SLOW_REQUEST       time.Duration = time.Second * 1 // 1s
 )
 
 type Pool struct {
-	nodes map[string]*Node // index the node with its address string
+	nodes  map[string]*Node // index the node with its address string
+	logger Logger
 	sync.Mutex
 }
 
@@ -31,7 +36,8 @@ func NewPool(cluster []string) *Pool {
 	}
 
 	pool := &Pool{
-		nodes: nodeMap,
+		nodes:  nodeMap,
+		logger: nopLogger{},
 	}
 
 	return pool
@@ -40,49 +46,145 @@ func NewPool(cluster []string) *Pool {
 // SelectNode returns a node from the pool using weighted error selection.
 //
 // Each node has an assignable error rate, which is incremented when an error
-// occurs, and decays over time - 50% each 10 seconds by default.
+// occurs, and decays over time - 50% each 10 seconds by default. The error
+// rate drives each node's circuit breaker, see breaker.go. Healthy and
+// degraded nodes are selected; half-open nodes only when nothing else is
+// available, and open nodes never.
 func (pool *Pool) SelectNode() (*Node, error) {
+	defer func() {
+		for _, node := range pool.nodes {
+			node.fireStateChanges()
+		}
+	}()
 	pool.Lock()
 	defer pool.Unlock()
 
-	var possibleNodes []*Node
+	var possibleNodes, probeNodes []*Node
 	for _, node := range pool.nodes {
-		if node.ErrorRate() < NODE_ERROR_THRESHOLD {
+		switch node.currentState() {
+		case NodeHealthy, NodeDegraded:
 			possibleNodes = append(possibleNodes, node)
+		case NodeHalfOpen:
+			probeNodes = append(probeNodes, node)
 		}
 	}
 
+	if len(possibleNodes) == 0 {
+		possibleNodes = probeNodes
+	}
+
 	count := len(possibleNodes)
 
 	if count > 0 {
 		return possibleNodes[rand.Int31n(int32(count))], nil
 	}
 
+	pool.logger.Error("all nodes down", "nodes", len(pool.nodes))
 	return nil, ErrAllNodesDown
 }
 
+// Ping health checks every node in the pool, see *Node.HealthCheck().
+//
+// Each check runs in its own goroutine, so Ping returns without waiting for
+// slow or unreachable nodes.
 func (pool *Pool) Ping() {
 	pool.Lock()
 	defer pool.Unlock()
 
 	for _, node := range pool.nodes {
-		nodeGood := node.Ping()
-		if nodeGood == false {
-			node.RecordError(0.1)
-			node.Lock()
-			node.Close()
-			node.Dial()
-			node.Unlock()
-		} else {
-			node.SetOk(true)
-		}
+		go node.HealthCheck()
+	}
+}
+
+// SetBreakerConfig sets the circuit breaker thresholds of every node.
+func (pool *Pool) SetBreakerConfig(config *BreakerConfig) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for _, node := range pool.nodes {
+		node.SetBreakerConfig(config)
+	}
+}
+
+// SetMetrics sets the Metrics notified after each request on every node.
+func (pool *Pool) SetMetrics(metrics Metrics) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for _, node := range pool.nodes {
+		node.SetMetrics(metrics)
+	}
+}
+
+// SetLogger sets the Logger of the pool and every node.
+func (pool *Pool) SetLogger(logger Logger) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.logger = logger
+	for _, node := range pool.nodes {
+		node.SetLogger(logger)
+	}
+}
 
+// SetSlowRequestThreshold sets the slow request threshold of every node, see
+// *Node.SetSlowRequestThreshold()
+func (pool *Pool) SetSlowRequestThreshold(threshold time.Duration) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for _, node := range pool.nodes {
+		node.SetSlowRequestThreshold(threshold)
+	}
+}
+
+// EnablePipelining switches every node to pipelined requests, see
+// *Node.EnablePipelining()
+func (pool *Pool) EnablePipelining(depth int) {
+	for _, node := range pool.nodes {
+		node.EnablePipelining(depth)
+	}
+}
+
+// DisablePipelining switches every node back to one request per round trip.
+func (pool *Pool) DisablePipelining() {
+	for _, node := range pool.nodes {
+		node.DisablePipelining()
+	}
+}
+
+// log returns the Logger of the pool.
+func (pool *Pool) log() Logger {
+	pool.Lock()
+	defer pool.Unlock()
+
+	return pool.logger
+}
+
+// Nodes returns the nodes in the pool.
+func (pool *Pool) Nodes() []*Node {
+	pool.Lock()
+	defer pool.Unlock()
+
+	nodes := make([]*Node, 0, len(pool.nodes))
+	for _, node := range pool.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// OnStateChange registers a state change callback on every node.
+func (pool *Pool) OnStateChange(fn StateChangeFunc) {
+	for _, node := range pool.nodes {
+		node.OnStateChange(fn)
 	}
 }
 
 func (pool *Pool) Close() {
 	for _, node := range pool.nodes {
+		node.Lock()
 		node.Close()
+		node.Unlock()
 	}
 }
 
@@ -93,7 +195,7 @@ func (pool *Pool) Size() int {
 func (pool *Pool) String() string {
 	var outString string
 	for _, node := range pool.nodes {
-		nodeString := fmt.Sprintf(" [%s %f <%t>] ", node.addr, node.ErrorRate(), node.GetOk())
+		nodeString := fmt.Sprintf(" [%s %f <%s>] ", node.addr, node.ErrorRate(), node.State())
 		outString += nodeString
 	}
 	return outString
@@ -1,5 +1,9 @@
 package riakpbc
 
+import (
+	"encoding/json"
+)
+
 // NewMapReduceRequest prepares a new MapReduce request.
 func (c *Client) NewMapReduceRequest(request, contentType string) *RpbMapRedReq {
 	return &RpbMapRedReq{
@@ -31,6 +35,44 @@ func (c *Client) MapReduce(request, contentType string) ([]byte, error) {
 	return c.mapReduce(nil, request, contentType)
 }
 
+// MapReducePhases executes a JSON-encoded MapReduce job like MapReduce, but
+// returns the results of each kept phase apart, keyed by phase number and
+// split into their elements.
+func (c *Client) MapReducePhases(request string) (phases map[uint32][]json.RawMessage, err error) {
+	opts := c.NewMapReduceRequest(request, "application/json")
+
+	span := c.startSpan("MapReducePhases", opts, "RpbMapRedReq")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	node, err := c.SelectNode()
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttribute(AttrNode, node.Addr())
+
+	responses, size, err := node.reqMapRedPhases(opts)
+	span.SetAttribute(AttrResponseSize, size)
+	if err != nil {
+		return nil, err
+	}
+
+	phases = map[uint32][]json.RawMessage{}
+	for _, response := range responses {
+		if len(response.GetResponse()) == 0 {
+			continue
+		}
+		var results []json.RawMessage
+		if err := json.Unmarshal(response.GetResponse(), &results); err != nil {
+			return nil, err
+		}
+		phases[response.GetPhase()] = append(phases[response.GetPhase()], results...)
+	}
+
+	return phases, nil
+}
+
 // NewIndexRequest prepares a new Index request.
 func (c *Client) NewIndexRequest(bucket, index, key, start, end string) *RpbIndexReq {
 	opts := &RpbIndexReq{
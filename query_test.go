@@ -2,7 +2,9 @@ package riakpbc
 
 import (
 	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
 	"os/exec"
+	"sync"
 	"testing"
 )
 
@@ -151,3 +153,54 @@ func TestSearchDo(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestMultiRespHoldsNode(t *testing.T) {
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		if structname == "RpbMapRedReq" {
+			return "RpbMapRedResp", testMessages{
+				&RpbMapRedResp{Phase: proto.Uint32(0), Response: []byte(`[1]`)},
+				&RpbMapRedResp{Phase: proto.Uint32(0), Response: []byte(`[2]`)},
+				&RpbMapRedResp{Done: proto.Bool(true)},
+			}
+		}
+		req := &RpbGetReq{}
+		proto.Unmarshal(body, req)
+		return "RpbGetResp", &RpbGetResp{Content: []*RpbContent{{Value: req.GetKey()}}}
+	})
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	// Follow-up messages of one request are never read by another
+	errs := make(chan string, 64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				phases, err := riak.MapReducePhases(`{}`)
+				if err != nil || len(phases[0]) != 2 {
+					errs <- "unexpected MapReduce results"
+					return
+				}
+				result, err := riak.MapReduce(`{}`, "application/json")
+				if err != nil || string(result) != `[1][2]` {
+					errs <- "unexpected MapReduce response"
+					return
+				}
+				obj, err := riak.FetchObject("bucket", "key")
+				if err != nil || string(obj.GetContent()[0].GetValue()) != "key" {
+					errs <- "unexpected fetch response"
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
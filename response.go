@@ -2,8 +2,6 @@ package riakpbc
 
 import (
 	"github.com/golang/protobuf/proto"
-	"errors"
-	"strconv"
 )
 
 var numToCommand = map[int]string{
@@ -73,7 +71,7 @@ func unmarshalResponse(respraw []byte) (respbuf interface{}, err error) {
 		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New(strconv.Itoa(int(respstruct.GetErrcode())) + ": " + string(string(respstruct.GetErrmsg())))
+		return nil, &RiakError{Code: respstruct.GetErrcode(), Message: string(respstruct.GetErrmsg())}
 
 	case "RpbPingResp":
 		return []byte("Pong"), nil
@@ -433,8 +433,33 @@ func (m *RpbDelReq) GetTimeout() uint32 {
 	return 0
 }
 
+type RpbListBucketsReq struct {
+	Timeout          *uint32 `protobuf:"varint,1,opt,name=timeout" json:"timeout,omitempty"`
+	Stream           *bool   `protobuf:"varint,2,opt,name=stream" json:"stream,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RpbListBucketsReq) Reset()         { *m = RpbListBucketsReq{} }
+func (m *RpbListBucketsReq) String() string { return proto.CompactTextString(m) }
+func (*RpbListBucketsReq) ProtoMessage()    {}
+
+func (m *RpbListBucketsReq) GetTimeout() uint32 {
+	if m != nil && m.Timeout != nil {
+		return *m.Timeout
+	}
+	return 0
+}
+
+func (m *RpbListBucketsReq) GetStream() bool {
+	if m != nil && m.Stream != nil {
+		return *m.Stream
+	}
+	return false
+}
+
 type RpbListBucketsResp struct {
 	Buckets          [][]byte `protobuf:"bytes,1,rep,name=buckets" json:"buckets,omitempty"`
+	Done             *bool    `protobuf:"varint,2,opt,name=done" json:"done,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -449,6 +474,13 @@ func (m *RpbListBucketsResp) GetBuckets() [][]byte {
 	return nil
 }
 
+func (m *RpbListBucketsResp) GetDone() bool {
+	if m != nil && m.Done != nil {
+		return *m.Done
+	}
+	return false
+}
+
 type RpbListKeysReq struct {
 	Bucket           []byte `protobuf:"bytes,1,req,name=bucket" json:"bucket,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
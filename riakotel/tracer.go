@@ -0,0 +1,68 @@
+/*
+Package riakotel traces riakpbc client operations with OpenTelemetry.
+
+	riak.SetTracer(riakotel.NewTracer(otel.Tracer("riakpbc")))
+	obj, err := riak.WithContext(ctx).FetchObject("bucket", "key")
+*/
+package riakotel
+
+import (
+	"context"
+	"fmt"
+	"github.com/mrb/riakpbc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer adapts an OpenTelemetry trace.Tracer to riakpbc.Tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a riakpbc.Tracer starting client spans on tracer.
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// StartSpan implements riakpbc.Tracer.
+func (t *Tracer) StartSpan(ctx context.Context, operation string) (context.Context, riakpbc.Span) {
+	ctx, span := t.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "riak")))
+	return ctx, &Span{span: span}
+}
+
+// Span adapts an OpenTelemetry trace.Span to riakpbc.Span.
+type Span struct {
+	span trace.Span
+}
+
+// SetAttribute implements riakpbc.Span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+// RecordError implements riakpbc.Span.
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End implements riakpbc.Span.
+func (s *Span) End() {
+	s.span.End()
+}
@@ -0,0 +1,116 @@
+/*
+Package riakprom exposes riakpbc client metrics to Prometheus.
+
+	collector := riakprom.NewCollector(riak.Pool())
+	riak.SetMetrics(collector)
+	prometheus.MustRegister(collector)
+*/
+package riakprom
+
+import (
+	"github.com/mrb/riakpbc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var nodeStates = []riakpbc.NodeState{
+	riakpbc.NodeHealthy,
+	riakpbc.NodeDegraded,
+	riakpbc.NodeOpen,
+	riakpbc.NodeHalfOpen,
+}
+
+// Collector records request metrics as a riakpbc.Metrics and reports them,
+// along with the state of every node in the pool, as a prometheus.Collector.
+type Collector struct {
+	pool        *riakpbc.Pool
+	latency     *prometheus.HistogramVec
+	bytesOut    *prometheus.CounterVec
+	bytesIn     *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	errorRate   *prometheus.Desc
+	connections *prometheus.Desc
+	state       *prometheus.Desc
+}
+
+// NewCollector returns a Collector reporting on the nodes of pool.
+func NewCollector(pool *riakpbc.Pool) *Collector {
+	return &Collector{
+		pool: pool,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "riak_request_duration_seconds",
+			Help:    "Riak request round trip latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "node"}),
+		bytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "riak_request_sent_bytes_total",
+			Help: "Bytes written to Riak nodes.",
+		}, []string{"operation", "node"}),
+		bytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "riak_request_received_bytes_total",
+			Help: "Bytes read from Riak nodes.",
+		}, []string{"operation", "node"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "riak_request_errors_total",
+			Help: "Riak requests that returned an error, by error class.",
+		}, []string{"operation", "node", "class"}),
+		errorRate: prometheus.NewDesc(
+			"riak_node_error_rate",
+			"Decaying error rate of a Riak node.",
+			[]string{"node"}, nil),
+		connections: prometheus.NewDesc(
+			"riak_node_connections",
+			"Open connections to a Riak node, serial and pipelined.",
+			[]string{"node"}, nil),
+		state: prometheus.NewDesc(
+			"riak_node_state",
+			"Circuit breaker state of a Riak node, 1 for the current state.",
+			[]string{"node", "state"}, nil),
+	}
+}
+
+// ObserveRequest implements riakpbc.Metrics.
+func (c *Collector) ObserveRequest(metric *riakpbc.RequestMetric) {
+	c.latency.WithLabelValues(metric.Operation, metric.Node).Observe(metric.Latency.Seconds())
+	c.bytesOut.WithLabelValues(metric.Operation, metric.Node).Add(float64(metric.BytesOut))
+	c.bytesIn.WithLabelValues(metric.Operation, metric.Node).Add(float64(metric.BytesIn))
+	if metric.Error != riakpbc.ErrorClassNone {
+		c.errors.WithLabelValues(metric.Operation, metric.Node, metric.Error).Inc()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.latency.Describe(ch)
+	c.bytesOut.Describe(ch)
+	c.bytesIn.Describe(ch)
+	c.errors.Describe(ch)
+	ch <- c.errorRate
+	ch <- c.connections
+	ch <- c.state
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.latency.Collect(ch)
+	c.bytesOut.Collect(ch)
+	c.bytesIn.Collect(ch)
+	c.errors.Collect(ch)
+
+	for _, node := range c.pool.Nodes() {
+		addr := node.Addr()
+		ch <- prometheus.MustNewConstMetric(c.errorRate, prometheus.GaugeValue, node.ErrorRate(), addr)
+		ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(node.Connections()), addr)
+
+		current := node.State()
+		for _, state := range nodeStates {
+			ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, boolValue(state == current), addr, state.String())
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
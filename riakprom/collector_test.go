@@ -0,0 +1,49 @@
+package riakprom
+
+import (
+	"github.com/mrb/riakpbc"
+	"github.com/prometheus/client_golang/prometheus"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	pool := riakpbc.NewPool([]string{"127.0.0.1:8087"})
+	collector := NewCollector(pool)
+	collector.ObserveRequest(&riakpbc.RequestMetric{
+		Operation: "RpbGetReq",
+		Node:      "127.0.0.1:8087",
+		Latency:   time.Millisecond,
+		BytesOut:  20,
+		BytesIn:   5,
+		Error:     riakpbc.ErrorClassNotFound,
+	})
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+	for _, family := range families {
+		found[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"riak_request_duration_seconds",
+		"riak_request_sent_bytes_total",
+		"riak_request_received_bytes_total",
+		"riak_request_errors_total",
+		"riak_node_error_rate",
+		"riak_node_connections",
+		"riak_node_state",
+	} {
+		if !found[name] {
+			t.Errorf("expected metric %s", name)
+		}
+	}
+}
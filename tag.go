@@ -0,0 +1,385 @@
+package riakpbc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var typeOfLinks = reflect.TypeOf([]*RpbLink(nil))
+var typeOfLink = reflect.TypeOf((*RpbLink)(nil))
+
+// riakDirectives are the names a `riak` tag directive can have. Any other
+// name in a tag is an option of the directive before it.
+var riakDirectives = map[string]bool{
+	"index":           true,
+	"meta":            true,
+	"link":            true,
+	"key":             true,
+	"vclock":          true,
+	"contenttype":     true,
+	"charset":         true,
+	"contentencoding": true,
+	"prefix":          true,
+}
+
+// riakTag is a single directive of a `riak` struct tag.
+//
+// `riak:"meta=owner"` has the value owner, `riak:"link,tag=friend"` has the
+// option tag=friend.
+type riakTag struct {
+	name    string
+	value   string
+	options map[string]string
+}
+
+// parseRiakTag splits a `riak` struct tag into its directives.
+func parseRiakTag(tag string) []*riakTag {
+	var directives []*riakTag
+
+	for _, part := range strings.Split(tag, ",") {
+		name, value := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			name, value = part[:i], part[i+1:]
+		}
+
+		if riakDirectives[name] || len(directives) == 0 {
+			directives = append(directives, &riakTag{
+				name:    name,
+				value:   value,
+				options: map[string]string{},
+			})
+		} else {
+			directives[len(directives)-1].options[name] = value
+		}
+	}
+
+	return directives
+}
+
+// structElem returns the struct pointed to by data.
+func structElem(data interface{}) (reflect.Value, error) {
+	t := reflect.ValueOf(data)
+	if t.Kind() != reflect.Ptr || t.IsNil() {
+		return reflect.Value{}, errors.New(fmt.Sprintf("Expected a pointer not %s", t.Kind()))
+	}
+	if t.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("Expected a pointer to a struct")
+	}
+	return t.Elem(), nil
+}
+
+// fieldFunc is called by walkFields for each field, with the directives of
+// its `riak` tag and the index name prefix in effect.
+type fieldFunc func(fld reflect.StructField, v reflect.Value, directives []*riakTag, prefix string) error
+
+// errStopWalk ends a walkFields early without it returning an error.
+var errStopWalk = errors.New("stop walk")
+
+// walkFields calls fn for every exported field of the struct e, first
+// descending into embedded structs, nested structs and non-nil pointers to
+// structs. A nested field tagged `riak:"prefix=home_"` prefixes the names of
+// the indexes found beneath it.
+func walkFields(e reflect.Value, fn fieldFunc) error {
+	err := walkStruct(e, "", map[uintptr]bool{}, fn)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func walkStruct(e reflect.Value, prefix string, visited map[uintptr]bool, fn fieldFunc) error {
+	for i := 0; i < e.NumField(); i++ {
+		fld := e.Type().Field(i)
+		if fld.PkgPath != "" && !fld.Anonymous {
+			continue
+		}
+
+		var directives []*riakTag
+		if tdata := fld.Tag.Get("riak"); tdata != "" {
+			directives = parseRiakTag(tdata)
+		}
+
+		if nested, nestedPrefix, ok := nestedStruct(e.Field(i), directives, visited); ok {
+			if err := walkStruct(nested, prefix+nestedPrefix, visited, fn); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(fld, e.Field(i), directives, prefix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nestedStruct returns the struct walkFields should descend into from a
+// field, along with the prefix the field adds. Fields with directives other
+// than `prefix`, and structs which are index values themselves such as
+// time.Time, are not descended into.
+func nestedStruct(v reflect.Value, directives []*riakTag, visited map[uintptr]bool) (reflect.Value, string, bool) {
+	prefix := ""
+	for _, directive := range directives {
+		if directive.name != "prefix" {
+			return reflect.Value{}, "", false
+		}
+		prefix = directive.value
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct || visited[v.Pointer()] {
+			return reflect.Value{}, "", false
+		}
+		visited[v.Pointer()] = true
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || v.Type() == typeOfTime {
+		return reflect.Value{}, "", false
+	}
+	if _, ok := indexEncoder(v); ok {
+		return reflect.Value{}, "", false
+	}
+
+	return v, prefix, true
+}
+
+// taggedField returns the first field of the struct pointed to by data
+// carrying the directive name, see walkFields().
+func taggedField(data interface{}, name string) (reflect.Value, bool) {
+	e, err := structElem(data)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+
+	var found reflect.Value
+	walkFields(e, func(fld reflect.StructField, v reflect.Value, directives []*riakTag, prefix string) error {
+		for _, directive := range directives {
+			if directive.name == name {
+				found = v
+				return errStopWalk
+			}
+		}
+		return nil
+	})
+
+	return found, found.IsValid()
+}
+
+// fieldBytes returns the value of a string or []byte field.
+func fieldBytes(v reflect.Value) []byte {
+	switch {
+	case v.Kind() == reflect.String:
+		return []byte(v.String())
+	case isBytes(v.Type()):
+		return v.Bytes()
+	}
+	return nil
+}
+
+// setFieldBytes sets a string or []byte field, leaving other kinds alone.
+func setFieldBytes(v reflect.Value, b []byte) {
+	if !v.CanSet() {
+		return
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(b))
+	case isBytes(v.Type()):
+		v.SetBytes(b)
+	}
+}
+
+// metaName is the usermeta key of a `meta` tagged string or []byte field.
+func metaName(fld reflect.StructField, directive *riakTag) string {
+	if directive.value != "" {
+		return directive.value
+	}
+	return strings.ToLower(fld.Name)
+}
+
+func marshalMeta(fld reflect.StructField, v reflect.Value, directive *riakTag) ([]*RpbPair, error) {
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String:
+		pairs := make([]*RpbPair, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			pairs = append(pairs, &RpbPair{
+				Key:   []byte(k.String()),
+				Value: []byte(v.MapIndex(k).String()),
+			})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return string(pairs[i].Key) < string(pairs[j].Key)
+		})
+		return pairs, nil
+	case v.Kind() == reflect.String, isBytes(v.Type()):
+		value := fieldBytes(v)
+		if len(value) == 0 {
+			return nil, nil
+		}
+		return []*RpbPair{{Key: []byte(metaName(fld, directive)), Value: value}}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("Field %s: meta expects a map[string]string, string or []byte", fld.Name))
+}
+
+func unmarshalMeta(v reflect.Value, directive *riakTag, fld reflect.StructField, usermeta []*RpbPair) {
+	if !v.CanSet() {
+		return
+	}
+
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String {
+		if len(usermeta) == 0 {
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, pair := range usermeta {
+			key := reflect.ValueOf(string(pair.GetKey())).Convert(v.Type().Key())
+			value := reflect.ValueOf(string(pair.GetValue())).Convert(v.Type().Elem())
+			v.SetMapIndex(key, value)
+		}
+		return
+	}
+
+	name := metaName(fld, directive)
+	for _, pair := range usermeta {
+		if string(pair.GetKey()) == name {
+			setFieldBytes(v, pair.GetValue())
+			return
+		}
+	}
+}
+
+// link builds the link for a `link` tagged string value.
+func link(value string, directive *riakTag) (*RpbLink, error) {
+	bucket := directive.options["bucket"]
+	if bucket == "" {
+		i := strings.Index(value, "/")
+		if i < 0 {
+			return nil, errors.New(fmt.Sprintf("Link %q has no bucket", value))
+		}
+		bucket, value = value[:i], value[i+1:]
+	}
+
+	l := &RpbLink{
+		Bucket: []byte(bucket),
+		Key:    []byte(value),
+	}
+	if tag := directive.options["tag"]; tag != "" {
+		l.Tag = []byte(tag)
+	}
+	return l, nil
+}
+
+func marshalLinks(fld reflect.StructField, v reflect.Value, directive *riakTag) ([]*RpbLink, error) {
+	var links []*RpbLink
+
+	switch {
+	case v.Kind() == reflect.String:
+		if v.String() != "" {
+			l, err := link(v.String(), directive)
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, l)
+		}
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < v.Len(); i++ {
+			l, err := link(v.Index(i).String(), directive)
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, l)
+		}
+	case v.Type() == typeOfLink:
+		if !v.IsNil() && v.CanInterface() {
+			links = append(links, v.Interface().(*RpbLink))
+		}
+	case v.Type() == typeOfLinks:
+		if v.CanInterface() {
+			links = append(links, v.Interface().([]*RpbLink)...)
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Field %s: link expects a string, []string, *RpbLink or []*RpbLink", fld.Name))
+	}
+
+	return links, nil
+}
+
+func unmarshalLinks(v reflect.Value, directive *riakTag, links []*RpbLink) {
+	if !v.CanSet() {
+		return
+	}
+
+	bucket := directive.options["bucket"]
+	tag := directive.options["tag"]
+
+	var matched []*RpbLink
+	for _, l := range links {
+		if tag != "" && string(l.GetTag()) != tag {
+			continue
+		}
+		if bucket != "" && string(l.GetBucket()) != bucket {
+			continue
+		}
+		matched = append(matched, l)
+	}
+
+	value := func(l *RpbLink) string {
+		if bucket != "" {
+			return string(l.GetKey())
+		}
+		return string(l.GetBucket()) + "/" + string(l.GetKey())
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		if len(matched) > 0 {
+			v.SetString(value(matched[0]))
+		}
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		values := reflect.MakeSlice(v.Type(), 0, len(matched))
+		for _, l := range matched {
+			values = reflect.Append(values, reflect.ValueOf(value(l)).Convert(v.Type().Elem()))
+		}
+		v.Set(values)
+	case v.Type() == typeOfLink:
+		if len(matched) > 0 {
+			v.Set(reflect.ValueOf(matched[0]))
+		}
+	case v.Type() == typeOfLinks:
+		v.Set(reflect.ValueOf(matched))
+	}
+}
+
+// structKey returns the value of the `key` tagged field of data, if any.
+func structKey(data interface{}) string {
+	if v, ok := taggedField(data, "key"); ok {
+		return string(fieldBytes(v))
+	}
+	return ""
+}
+
+// structVclock returns the value of the `vclock` tagged field of data, if any.
+func structVclock(data interface{}) []byte {
+	if v, ok := taggedField(data, "vclock"); ok {
+		return fieldBytes(v)
+	}
+	return nil
+}
+
+// setStructObject fills the `key` and `vclock` tagged fields of data.
+func setStructObject(data interface{}, key string, vclock []byte) {
+	if v, ok := taggedField(data, "key"); ok && key != "" {
+		setFieldBytes(v, []byte(key))
+	}
+	if v, ok := taggedField(data, "vclock"); ok && vclock != nil {
+		setFieldBytes(v, vclock)
+	}
+}
@@ -0,0 +1,150 @@
+package riakpbc
+
+import (
+	"context"
+)
+
+// Span attribute keys set by the client.
+const (
+	AttrBucket       = "riak.bucket"
+	AttrKey          = "riak.key"
+	AttrNode         = "riak.node"
+	AttrMessageCode  = "riak.message_code"
+	AttrResponseSize = "riak.response_size"
+	AttrAttempts     = "riak.attempts"
+)
+
+// Tracer starts a span for every client operation. It is an adapter point for
+// tracing libraries such as OpenTelemetry, see the riakotel package, so that
+// riakpbc itself does not depend on one.
+type Tracer interface {
+	// StartSpan starts a span named operation as a child of any span
+	// carried by ctx.
+	StartSpan(ctx context.Context, operation string) (context.Context, Span)
+}
+
+// Span is a single traced operation, see Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// operationNames maps request messages to the client operation sending them
+// when the request doesn't say, such as those sent with *Client.ReqResp().
+var operationNames = map[string]string{
+	"RpbPingReq":          "Ping",
+	"RpbGetClientIdReq":   "GetClientId",
+	"RpbSetClientIdReq":   "SetClientId",
+	"RpbGetServerInfoReq": "GetServerInfo",
+	"RpbGetReq":           "FetchObject",
+	"RpbPutReq":           "StoreObject",
+	"RpbDelReq":           "DeleteObject",
+	"RpbListBucketsReq":   "ListBuckets",
+	"RpbListKeysReq":      "ListKeys",
+	"RpbGetBucketReq":     "GetBucket",
+	"RpbSetBucketReq":     "SetBucket",
+	"RpbMapRedReq":        "MapReduce",
+	"RpbIndexReq":         "Index",
+	"RpbSearchQueryReq":   "Search",
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// SetTracer sets the Tracer used to trace every operation of the client.
+func (c *Client) SetTracer(tracer Tracer) {
+	c.tracer = tracer
+}
+
+// WithContext returns a shallow copy of the client whose operations are
+// traced as children of ctx. The copy shares the pool of the original.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	copied := *c
+	copied.ctx = ctx
+	return &copied
+}
+
+// Context returns the context of the client, see WithContext().
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// operationName returns the client operation sending structname requests.
+func operationName(structname string) string {
+	if operation, ok := operationNames[structname]; ok {
+		return operation
+	}
+	return structname
+}
+
+// startSpan starts a span for a single request of the client operation
+// operation.
+func (c *Client) startSpan(operation string, reqstruct interface{}, structname string) Span {
+	if c.tracer == nil {
+		return noopSpan{}
+	}
+
+	_, span := c.tracer.StartSpan(c.Context(), "riak."+operation)
+	span.SetAttribute(AttrMessageCode, int(commandToNum[structname]))
+	if req, ok := reqstruct.(interface {
+		GetBucket() []byte
+	}); ok {
+		span.SetAttribute(AttrBucket, string(req.GetBucket()))
+	}
+	if req, ok := reqstruct.(interface {
+		GetKey() []byte
+	}); ok {
+		span.SetAttribute(AttrKey, string(req.GetKey()))
+	}
+
+	return span
+}
+
+// startOperation starts a span for an operation sending several requests,
+// returning a copy of the client whose requests are traced as its children.
+func (c *Client) startOperation(operation string) (*Client, Span) {
+	if c.tracer == nil {
+		return c, noopSpan{}
+	}
+
+	ctx, span := c.tracer.StartSpan(c.Context(), "riak."+operation)
+	return c.WithContext(ctx), span
+}
+
+// startOperation is *Client.startOperation() for a typed bucket, returning a
+// copy of the bucket on the traced client.
+func (b *Bucket[T]) startOperation(operation string) (*Bucket[T], Span) {
+	client, span := b.client.startOperation(operation)
+	span.SetAttribute(AttrBucket, b.name)
+
+	traced := *b
+	traced.client = client
+	return &traced, span
+}
+
+// startOperation is *Client.startOperation() for a link walk, returning a
+// copy of the walk on the traced client.
+func (w *Walk) startOperation(operation string) (*Walk, Span) {
+	client, span := w.client.startOperation(operation)
+	span.SetAttribute(AttrBucket, w.bucket)
+	span.SetAttribute(AttrKey, w.key)
+
+	traced := *w
+	traced.client = client
+	return &traced, span
+}
+
+// endSpan ends span, recording err unless it is a plain not found.
+func endSpan(span Span, err error) {
+	if err != nil && err != ErrObjectNotFound {
+		span.RecordError(err)
+	}
+	span.End()
+}
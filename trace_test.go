@@ -0,0 +1,186 @@
+package riakpbc
+
+import (
+	"context"
+	"fmt"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type testSpanKey struct{}
+
+type testTracer struct {
+	sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, operation string) (context.Context, Span) {
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{name: operation, parent: parent, attrs: map[string]interface{}{}}
+	t.Lock()
+	t.spans = append(t.spans, span)
+	t.Unlock()
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+// named returns the spans started with name, in order.
+func (t *testTracer) named(name string) []*testSpan {
+	var spans []*testSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	riak := NewClient([]string{"127.0.0.1:1"})
+	riak.SetTracer(tracer)
+
+	_, err := riak.WithContext(context.Background()).FetchObject("tracebucket", "tracekey")
+	assert.T(t, err != nil)
+
+	assert.T(t, len(tracer.spans) == 1)
+	span := tracer.spans[0]
+	assert.T(t, span.name == "riak.FetchObject")
+	assert.T(t, span.attrs[AttrBucket] == "tracebucket")
+	assert.T(t, span.attrs[AttrKey] == "tracekey")
+	assert.T(t, span.attrs[AttrNode] == "127.0.0.1:1")
+	assert.T(t, span.attrs[AttrMessageCode] == 9)
+	_, counted := span.attrs[AttrAttempts]
+	assert.T(t, !counted)
+	assert.T(t, span.parent == nil)
+	assert.T(t, span.err == err)
+	assert.T(t, span.ended)
+}
+
+func TestTracerOperationNames(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	tracer := &testTracer{}
+	riak := NewClient([]string{addr})
+	riak.SetTracer(tracer)
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	riak.StoreObject("bucket", "key", "value")
+	riak.FetchMeta("bucket", "key")
+	riak.Exists("bucket", "key")
+	riak.FetchIfModified("bucket", "key", []byte("vclock"))
+	riak.FetchTombstone("bucket", "key")
+	riak.Insert("bucket", "key", "value")
+
+	names := make([]string, len(tracer.spans))
+	for i, span := range tracer.spans {
+		names[i] = span.name
+	}
+	assert.Equal(t, []string{
+		"riak.StoreObject",
+		"riak.FetchMeta",
+		"riak.Exists",
+		"riak.FetchIfModified",
+		"riak.FetchTombstone",
+		"riak.Insert",
+	}, names)
+}
+
+func TestTracerMultiResponseSize(t *testing.T) {
+	addr := newTestServer(t, func(structname string, body []byte) (string, proto.Message) {
+		switch structname {
+		case "RpbListKeysReq":
+			return "RpbListKeysResp", testMessages{
+				&RpbListKeysResp{Keys: [][]byte{[]byte("a")}},
+				&RpbListKeysResp{Done: proto.Bool(true)},
+			}
+		case "RpbMapRedReq":
+			return "RpbMapRedResp", testMessages{
+				&RpbMapRedResp{Phase: proto.Uint32(0), Response: []byte(`[1]`)},
+				&RpbMapRedResp{Done: proto.Bool(true)},
+			}
+		case "RpbListBucketsReq":
+			return "RpbListBucketsResp", testMessages{
+				&RpbListBucketsResp{Buckets: [][]byte{[]byte("b")}},
+				&RpbListBucketsResp{Done: proto.Bool(true)},
+			}
+		}
+		return "", nil
+	})
+
+	tracer := &testTracer{}
+	riak := NewClient([]string{addr})
+	riak.SetTracer(tracer)
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	_, err := riak.ListKeys("bucket")
+	assert.T(t, err == nil)
+	_, err = riak.MapReducePhases(`{}`)
+	assert.T(t, err == nil)
+	err = riak.StreamBuckets(0, func([][]byte) error { return nil })
+	assert.T(t, err == nil)
+
+	assert.Equal(t, 3, len(tracer.spans))
+	for _, span := range tracer.spans {
+		size, _ := span.attrs[AttrResponseSize].(int)
+		assert.Tf(t, size > 0, "%s has no response size", span.name)
+	}
+}
+
+func TestTracerOperations(t *testing.T) {
+	server, riak := setupGraph(t)
+	defer riak.Close()
+
+	tracer := &testTracer{}
+	riak.SetTracer(tracer)
+
+	riak.FetchMany("people", []string{"a", "b", "c"}, nil)
+	batches := tracer.named("riak.FetchMany")
+	assert.Equal(t, 1, len(batches))
+	assert.T(t, batches[0].ended)
+	fetches := tracer.named("riak.FetchObject")
+	assert.Equal(t, 3, len(fetches))
+	for _, fetch := range fetches {
+		assert.T(t, fetch.parent == batches[0])
+	}
+
+	started := len(tracer.spans)
+	_, err := riak.Walk("people", "a").Link("people", "friend", true).Run()
+	assert.T(t, err == nil)
+	walks := tracer.named("riak.Walk")
+	assert.Equal(t, 1, len(walks))
+	assert.T(t, len(tracer.spans) > started+1)
+	for _, span := range tracer.spans[started+1:] {
+		assert.Tf(t, span.parent == walks[0], "%s is not a child of the walk", span.name)
+	}
+
+	// A concurrent write lands between the first two fetches and stores
+	puts := 0
+	server.Lock()
+	server.beforePut = func() {
+		puts++
+		if puts <= 2 {
+			server.objects["people/e"].vclock = []byte(fmt.Sprintf("concurrent%d", puts))
+		}
+	}
+	server.Unlock()
+	assert.T(t, riak.LinkAdd("people", "e", "people", "a", "friend") == nil)
+	updates := tracer.named("riak.UpdateLinks")
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, 3, updates[0].attrs[AttrAttempts])
+}
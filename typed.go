@@ -0,0 +1,182 @@
+package riakpbc
+
+import (
+	"reflect"
+)
+
+// Bucket is a typed handle on a bucket, storing and fetching values of T
+// through a Coder. T is a struct type or a pointer to one, and may carry
+// `riak` tags as described in Coder.Marshal().
+//
+// Example:
+//
+//	users := riakpbc.NewBucket[User](client, "users")
+//	err := users.Put("bob", User{Name: "Bob"})
+//	user, meta, err := users.Get("bob")
+type Bucket[T any] struct {
+	client *Client
+	name   string
+	coder  *Coder
+}
+
+// NewBucket returns a handle on the bucket name of client. Values are
+// encoded with the client's Coder unless SetCoder is called.
+func NewBucket[T any](client *Client, name string) *Bucket[T] {
+	return &Bucket[T]{
+		client: client,
+		name:   name,
+	}
+}
+
+// Name returns the name of the bucket.
+func (b *Bucket[T]) Name() string {
+	return b.name
+}
+
+// SetCoder sets the Coder values are encoded and decoded with, in place of
+// the client's.
+func (b *Bucket[T]) SetCoder(coder *Coder) {
+	b.coder = coder
+}
+
+// target returns a pointer to the struct held by *value for the Coder to work
+// on, allocating it when T is a pointer type.
+func (b *Bucket[T]) target(value *T) interface{} {
+	v := reflect.ValueOf(value).Elem()
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface()
+	}
+	return value
+}
+
+// encoder returns the Coder to store values with.
+func (b *Bucket[T]) encoder() (*Coder, error) {
+	if b.coder != nil {
+		return b.coder, nil
+	}
+	if b.client.Coder != nil {
+		return b.client.Coder, nil
+	}
+	return nil, ErrNoCoder
+}
+
+// decoder returns the Coder to decode content of contentType with.
+func (b *Bucket[T]) decoder(contentType string) (*Coder, error) {
+	if b.coder != nil {
+		return b.coder, nil
+	}
+	if coder := b.client.CoderFor(contentType); coder != nil {
+		return coder, nil
+	}
+	return nil, ErrNoCoder
+}
+
+// Get fetches and decodes the value stored under key, along with its
+// metadata. When the object has siblings the first is decoded.
+func (b *Bucket[T]) Get(key string, opts ...*GetOptions) (T, *Meta, error) {
+	var value T
+
+	response, err := b.client.fetchObject("Get", b.client.getRequest(b.name, key, opts), b.name, key)
+	if err != nil {
+		return value, nil, err
+	}
+	if len(response.GetContent()) == 0 {
+		return value, nil, ErrObjectNotFound
+	}
+
+	content := response.GetContent()[0]
+	coder, err := b.decoder(string(content.GetContentType()))
+	if err != nil {
+		return value, nil, err
+	}
+
+	data := b.target(&value)
+	if err := coder.UnmarshalContent(content, data); err != nil {
+		return value, nil, err
+	}
+	setStructObject(data, key, response.GetVclock())
+
+	return value, newMeta(key, response.GetVclock(), content, len(response.GetContent())), nil
+}
+
+// Put encodes value and stores it under key. An empty key is taken from the
+// `riak:"key"` field of value, and the vclock from its `riak:"vclock"` field.
+//
+// Pass PutOptions for optional parameters; a Vclock set there takes
+// precedence over the field.
+func (b *Bucket[T]) Put(key string, value T, opts ...*PutOptions) error {
+	_, err := b.put(key, value, opts)
+	return err
+}
+
+func (b *Bucket[T]) put(key string, value T, opts []*PutOptions) (*RpbPutResp, error) {
+	coder, err := b.encoder()
+	if err != nil {
+		return nil, err
+	}
+
+	data := b.target(&value)
+	content, err := coder.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		key = structKey(data)
+	}
+
+	req := b.client.putRequest(b.name, key, opts)
+	if req.Vclock == nil {
+		req.Vclock = structVclock(data)
+	}
+
+	return b.client.storeObject("Put", req, b.name, key, content)
+}
+
+// Delete removes the value stored under key.
+func (b *Bucket[T]) Delete(key string, opts ...*DeleteOptions) error {
+	_, err := b.client.deleteObject("Delete", b.client.deleteRequest(b.name, key, opts), b.name, key)
+	return err
+}
+
+// Keys lists all keys of the bucket.
+//
+// Listing keys walks every key in the cluster, and is not meant for
+// production use.
+func (b *Bucket[T]) Keys() ([]string, error) {
+	keys, err := b.client.listKeys(nil, b.name)
+	if err != nil {
+		return nil, err
+	}
+	return stringKeys(keys), nil
+}
+
+// Index returns the keys whose index matches key exactly.
+func (b *Bucket[T]) Index(index, key string) ([]string, error) {
+	response, err := b.client.index(nil, b.name, index, key, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return stringKeys(response.GetKeys()), nil
+}
+
+// IndexRange returns the keys whose index lies between start and end,
+// inclusive.
+func (b *Bucket[T]) IndexRange(index, start, end string) ([]string, error) {
+	response, err := b.client.index(nil, b.name, index, "", start, end)
+	if err != nil {
+		return nil, err
+	}
+	return stringKeys(response.GetKeys()), nil
+}
+
+func stringKeys(keys [][]byte) []string {
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[i] = string(key)
+	}
+	return out
+}
@@ -0,0 +1,302 @@
+package riakpbc
+
+import (
+	"bytes"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memObject is an object held by a memServer.
+type memObject struct {
+	content []*RpbContent
+	vclock  []byte
+	deleted bool // a tombstone, with a vclock but no content
+}
+
+// memServer is an in memory Riak node for tests, keeping objects by bucket
+// and key.
+type memServer struct {
+	sync.Mutex
+	objects  map[string]*memObject
+	requests map[string][]byte // the last request body of each message
+	clock    int
+
+	noMapReduce   bool   // fail MapReduce jobs, as a cluster with it disabled does
+	dropMapReduce bool   // close the connection on MapReduce jobs
+	beforePut     func() // called with the lock held before each put
+}
+
+// newMemServer starts a memServer on a local port and returns it along with
+// its address.
+func newMemServer(t *testing.T) (*memServer, string) {
+	s := &memServer{objects: map[string]*memObject{}, requests: map[string][]byte{}}
+	return s, newTestServer(t, s.handle)
+}
+
+// object returns the live object stored in bucket under key, if any.
+func (s *memServer) object(bucket, key string) *memObject {
+	s.Lock()
+	defer s.Unlock()
+	if obj := s.objects[bucket+"/"+key]; obj != nil && !obj.deleted {
+		return obj
+	}
+	return nil
+}
+
+// lastRequest decodes the last request named structname into req.
+func (s *memServer) lastRequest(structname string, req proto.Message) {
+	s.Lock()
+	defer s.Unlock()
+	proto.Unmarshal(s.requests[structname], req)
+}
+
+func (s *memServer) handle(structname string, body []byte) (string, proto.Message) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests[structname] = body
+
+	switch structname {
+	case "RpbGetReq":
+		req := &RpbGetReq{}
+		proto.Unmarshal(body, req)
+		obj, ok := s.objects[string(req.GetBucket())+"/"+string(req.GetKey())]
+		if !ok {
+			return "RpbGetResp", nil
+		}
+		if obj.deleted {
+			if req.GetDeletedvclock() {
+				return "RpbGetResp", &RpbGetResp{Vclock: obj.vclock}
+			}
+			return "RpbGetResp", nil
+		}
+		if req.IfModified != nil && bytes.Equal(req.GetIfModified(), obj.vclock) {
+			return "RpbGetResp", &RpbGetResp{Unchanged: proto.Bool(true)}
+		}
+		resp := &RpbGetResp{Content: obj.content, Vclock: obj.vclock}
+		if req.GetHead() {
+			resp.Content = make([]*RpbContent, len(obj.content))
+			for i, content := range obj.content {
+				head := *content
+				head.Value = []byte{}
+				resp.Content[i] = &head
+			}
+		}
+		return "RpbGetResp", resp
+
+	case "RpbPutReq":
+		if s.beforePut != nil {
+			s.beforePut()
+		}
+		req := &RpbPutReq{}
+		proto.Unmarshal(body, req)
+		if req.Key != nil && len(req.Key) == 0 {
+			return riakError("Invalid key")
+		}
+		key := string(req.GetKey())
+		if req.Key == nil {
+			key = "generated" + strconv.Itoa(len(s.objects))
+		}
+
+		current, exists := s.objects[string(req.GetBucket())+"/"+key]
+		exists = exists && !current.deleted
+		switch {
+		case req.GetIfNoneMatch() && exists:
+			return riakError("match_found")
+		case req.GetIfNotModified() && !exists:
+			return riakError("notfound")
+		case req.GetIfNotModified() && !bytes.Equal(current.vclock, req.GetVclock()):
+			return riakError("modified")
+		}
+
+		s.clock++
+		obj := &memObject{
+			content: []*RpbContent{req.GetContent()},
+			vclock:  []byte("vclock" + strconv.Itoa(s.clock)),
+		}
+		s.objects[string(req.GetBucket())+"/"+key] = obj
+
+		resp := &RpbPutResp{Vclock: obj.vclock}
+		if req.Key == nil {
+			resp.Key = []byte(key)
+		}
+		if req.GetReturnBody() {
+			resp.Content = obj.content
+		}
+		return "RpbPutResp", resp
+
+	case "RpbDelReq":
+		req := &RpbDelReq{}
+		proto.Unmarshal(body, req)
+		if obj, ok := s.objects[string(req.GetBucket())+"/"+string(req.GetKey())]; ok && !obj.deleted {
+			s.clock++
+			obj.content = nil
+			obj.vclock = []byte("vclock" + strconv.Itoa(s.clock))
+			obj.deleted = true
+		}
+		return "RpbDelResp", nil
+
+	case "RpbListBucketsReq":
+		req := &RpbListBucketsReq{}
+		proto.Unmarshal(body, req)
+		seen := map[string]bool{}
+		var buckets [][]byte
+		for name, obj := range s.objects {
+			if bucket, _, _ := strings.Cut(name, "/"); !seen[bucket] && !obj.deleted {
+				seen[bucket] = true
+				buckets = append(buckets, []byte(bucket))
+			}
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			return bytes.Compare(buckets[i], buckets[j]) < 0
+		})
+		if !req.GetStream() {
+			return "RpbListBucketsResp", &RpbListBucketsResp{Buckets: buckets}
+		}
+		var messages testMessages
+		for _, bucket := range buckets {
+			messages = append(messages, &RpbListBucketsResp{Buckets: [][]byte{bucket}})
+		}
+		return "RpbListBucketsResp", append(messages, &RpbListBucketsResp{Done: proto.Bool(true)})
+
+	case "RpbListKeysReq":
+		req := &RpbListKeysReq{}
+		proto.Unmarshal(body, req)
+		done := true
+		resp := &RpbListKeysResp{Done: &done}
+		for name, obj := range s.objects {
+			if bucket, key, _ := strings.Cut(name, "/"); bucket == string(req.GetBucket()) && !obj.deleted {
+				resp.Keys = append(resp.Keys, []byte(key))
+			}
+		}
+		sort.Slice(resp.Keys, func(i, j int) bool {
+			return bytes.Compare(resp.Keys[i], resp.Keys[j]) < 0
+		})
+		return "RpbListKeysResp", resp
+
+	case "RpbIndexReq":
+		req := &RpbIndexReq{}
+		proto.Unmarshal(body, req)
+		resp := &RpbIndexResp{}
+		for name, obj := range s.objects {
+			bucket, key, _ := strings.Cut(name, "/")
+			if bucket != string(req.GetBucket()) || obj.deleted {
+				continue
+			}
+			for _, index := range obj.content[0].GetIndexes() {
+				if !bytes.Equal(index.GetKey(), req.GetIndex()) {
+					continue
+				}
+				value := string(index.GetValue())
+				if req.GetQtype() == 0 && value == string(req.GetKey()) ||
+					req.GetQtype() == 1 && value >= string(req.GetRangeMin()) && value <= string(req.GetRangeMax()) {
+					resp.Keys = append(resp.Keys, []byte(key))
+					break
+				}
+			}
+		}
+		sort.Slice(resp.Keys, func(i, j int) bool {
+			return bytes.Compare(resp.Keys[i], resp.Keys[j]) < 0
+		})
+		return "RpbIndexResp", resp
+
+	case "RpbMapRedReq":
+		if s.dropMapReduce {
+			return "", nil
+		}
+		if s.noMapReduce {
+			return riakError("MapReduce is disabled")
+		}
+		req := &RpbMapRedReq{}
+		proto.Unmarshal(body, req)
+		return "RpbMapRedResp", s.linkPhases(req.GetRequest())
+	}
+
+	return "RpbPingResp", nil
+}
+
+// riakError answers a request with an error response.
+func riakError(msg string) (string, proto.Message) {
+	return "RpbErrorResp", &RpbErrorResp{Errmsg: []byte(msg), Errcode: proto.Uint32(0)}
+}
+
+type typedUser struct {
+	Key    string            `json:"-" riak:"key"`
+	Vclock []byte            `json:"-" riak:"vclock"`
+	Name   string            `json:"name" riak:"index"`
+	Meta   map[string]string `json:"-" riak:"meta"`
+}
+
+func TestBucket(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	riak.Coder = NewJsonCoder()
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	users := NewBucket[typedUser](riak, "users")
+	assert.Equal(t, "users", users.Name())
+
+	err := users.Put("bob", typedUser{Name: "Bob", Meta: map[string]string{"role": "admin"}})
+	assert.T(t, err == nil)
+	err = users.Put("", typedUser{Key: "alice", Name: "Alice"})
+	assert.T(t, err == nil)
+	assert.T(t, server.object("users", "alice") != nil)
+
+	user, meta, err := users.Get("bob")
+	assert.T(t, err == nil)
+	assert.Equal(t, "Bob", user.Name)
+	assert.Equal(t, "bob", user.Key)
+	assert.Equal(t, "admin", user.Meta["role"])
+	assert.Equal(t, []byte("vclock1"), user.Vclock)
+	assert.Equal(t, "bob", meta.Key)
+	assert.Equal(t, []byte("vclock1"), meta.Vclock)
+	assert.Equal(t, "application/json", meta.ContentType)
+	assert.Equal(t, "admin", meta.Usermeta["role"])
+	assert.Equal(t, 1, meta.Siblings)
+
+	// The vclock field is sent along with the value
+	user.Name = "Robert"
+	assert.T(t, users.Put("bob", user) == nil)
+
+	keys, err := users.Keys()
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"alice", "bob"}, keys)
+
+	keys, err = users.Index("name_bin", "Robert")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"bob"}, keys)
+
+	keys, err = users.IndexRange("name_bin", "A", "Z")
+	assert.T(t, err == nil)
+	assert.Equal(t, []string{"alice", "bob"}, keys)
+
+	assert.T(t, users.Delete("bob") == nil)
+	_, _, err = users.Get("bob")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestBucketPointer(t *testing.T) {
+	_, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	users := NewBucket[*typedUser](riak, "users")
+	assert.Equal(t, ErrNoCoder, users.Put("bob", &typedUser{Name: "Bob"}))
+
+	users.SetCoder(NewJsonCoder())
+	assert.T(t, users.Put("bob", &typedUser{Name: "Bob"}) == nil)
+
+	user, _, err := users.Get("bob")
+	assert.T(t, err == nil)
+	assert.Equal(t, "Bob", user.Name)
+	assert.Equal(t, "bob", user.Key)
+}
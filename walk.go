@@ -0,0 +1,282 @@
+package riakpbc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Walk is a link walk starting at one object and following its links step
+// by step, see *Client.Walk().
+type Walk struct {
+	client *Client
+	bucket string
+	key    string
+	steps  []*walkStep
+}
+
+type walkStep struct {
+	bucket string
+	tag    string
+	keep   bool
+}
+
+// WalkObject is an object reached by a link walk or traversal, along with the
+// tag of the link that led to it.
+type WalkObject struct {
+	Bucket string
+	Key    string
+	Tag    string
+	Depth  int // links followed from the start
+	Object *RpbGetResp
+}
+
+// Walk starts a link walk at the object bucket/key. Add steps with Link, then
+// Run it:
+//
+//	// The friends of bob, and the friends of those
+//	steps, err := client.Walk("people", "bob").
+//		Link("people", "friend", true).
+//		Link("people", "friend", true).
+//		Run()
+func (c *Client) Walk(bucket, key string) *Walk {
+	return &Walk{
+		client: c,
+		bucket: bucket,
+		key:    key,
+	}
+}
+
+// Link adds a step following the links of the objects reached so far to
+// bucket with tag. An empty bucket or tag matches any. keep returns the
+// objects the step reaches; the last step is always kept.
+func (w *Walk) Link(bucket, tag string, keep bool) *Walk {
+	w.steps = append(w.steps, &walkStep{bucket: bucket, tag: tag, keep: keep})
+	return w
+}
+
+// kept reports whether the objects of step i are returned.
+func (w *Walk) kept(i int) bool {
+	return w.steps[i].keep || i == len(w.steps)-1
+}
+
+// wildcard is how a link phase matches any bucket or tag.
+func wildcard(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// Job returns the JSON MapReduce job the walk compiles to: one link phase
+// per step.
+func (w *Walk) Job() (string, error) {
+	type link struct {
+		Bucket string `json:"bucket"`
+		Tag    string `json:"tag"`
+		Keep   bool   `json:"keep"`
+	}
+	type phase struct {
+		Link link `json:"link"`
+	}
+
+	job := struct {
+		Inputs [][]string `json:"inputs"`
+		Query  []phase    `json:"query"`
+	}{
+		Inputs: [][]string{{w.bucket, w.key}},
+	}
+	for i, step := range w.steps {
+		job.Query = append(job.Query, phase{link{wildcard(step.bucket), wildcard(step.tag), w.kept(i)}})
+	}
+
+	data, err := json.Marshal(job)
+	return string(data), err
+}
+
+// Run walks the links with a MapReduce job, falling back to fetching the
+// objects of each step in turn if Riak fails the job, as it does when
+// MapReduce is disabled on the cluster or a phase fails. Errors reaching Riak
+// are returned as they are. It returns the objects reached by every kept
+// step, in the order of the steps. Links to objects which don't exist are
+// left out.
+func (w *Walk) Run() (steps [][]*WalkObject, err error) {
+	w, span := w.startOperation("Walk")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	phases, err := w.mapReduce()
+	if _, ok := err.(*RiakError); ok {
+		return w.runFetches()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w.mapReduceSteps(phases)
+}
+
+// RunMapReduce walks the links with a MapReduce job only, see Run().
+func (w *Walk) RunMapReduce() (steps [][]*WalkObject, err error) {
+	w, span := w.startOperation("Walk")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	phases, err := w.mapReduce()
+	if err != nil {
+		return nil, err
+	}
+	return w.mapReduceSteps(phases)
+}
+
+// mapReduce runs the MapReduce job of the walk.
+func (w *Walk) mapReduce() (map[uint32][]json.RawMessage, error) {
+	if len(w.steps) == 0 {
+		return nil, errors.New("Walk has no steps")
+	}
+
+	job, err := w.Job()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.client.MapReducePhases(job)
+}
+
+// mapReduceSteps fetches the objects reached by each kept step of the
+// MapReduce job.
+func (w *Walk) mapReduceSteps(phases map[uint32][]json.RawMessage) ([][]*WalkObject, error) {
+	var steps [][]*WalkObject
+	for i := range w.steps {
+		if !w.kept(i) {
+			continue
+		}
+
+		var reached []*WalkObject
+		for _, result := range phases[uint32(i)] {
+			var link []string
+			if err := json.Unmarshal(result, &link); err != nil || len(link) < 2 {
+				return nil, errors.New(fmt.Sprintf("Unexpected link phase result %s", result))
+			}
+			obj := &WalkObject{Bucket: link[0], Key: link[1], Depth: i + 1}
+			if len(link) > 2 {
+				obj.Tag = link[2]
+			}
+			reached = append(reached, obj)
+		}
+
+		reached, err := w.client.fetchWalkObjects(uniqueWalkObjects(reached), DefaultBatchConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, reached)
+	}
+
+	return steps, nil
+}
+
+// RunFetches walks the links by fetching the objects of each step in turn,
+// without MapReduce, see Run().
+func (w *Walk) RunFetches() (steps [][]*WalkObject, err error) {
+	w, span := w.startOperation("Walk")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return w.runFetches()
+}
+
+// runFetches is RunFetches without its span.
+func (w *Walk) runFetches() ([][]*WalkObject, error) {
+	if len(w.steps) == 0 {
+		return nil, errors.New("Walk has no steps")
+	}
+
+	start, err := w.client.FetchObject(w.bucket, w.key)
+	if err != nil {
+		return nil, err
+	}
+	current := []*WalkObject{{Bucket: w.bucket, Key: w.key, Object: start}}
+
+	var steps [][]*WalkObject
+	for i, step := range w.steps {
+		current, err = w.client.fetchWalkObjects(linkedObjects(current, step.bucket, step.tag), DefaultBatchConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		if w.kept(i) {
+			steps = append(steps, current)
+		}
+	}
+
+	return steps, nil
+}
+
+// linkedObjects returns the objects the links of objs point to, sorted and
+// without repeats, following only those to bucket with tag. An empty bucket or
+// tag matches any.
+func linkedObjects(objs []*WalkObject, bucket, tag string) []*WalkObject {
+	var reached []*WalkObject
+	for _, obj := range objs {
+		for _, content := range obj.Object.GetContent() {
+			for _, l := range content.GetLinks() {
+				if bucket != "" && string(l.GetBucket()) != bucket {
+					continue
+				}
+				if tag != "" && string(l.GetTag()) != tag {
+					continue
+				}
+				reached = append(reached, &WalkObject{
+					Bucket: string(l.GetBucket()),
+					Key:    string(l.GetKey()),
+					Tag:    string(l.GetTag()),
+					Depth:  obj.Depth + 1,
+				})
+			}
+		}
+	}
+	return uniqueWalkObjects(reached)
+}
+
+// fetchWalkObjects fetches reached objects, at most concurrency at a time,
+// leaving out those which don't exist.
+func (c *Client) fetchWalkObjects(reached []*WalkObject, concurrency int) ([]*WalkObject, error) {
+	errs := make([]error, len(reached))
+	batch(len(reached), concurrency, func(i int) {
+		reached[i].Object, errs[i] = c.FetchObject(reached[i].Bucket, reached[i].Key)
+	})
+
+	fetched := reached[:0]
+	for i, obj := range reached {
+		if errs[i] == ErrObjectNotFound {
+			continue
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		fetched = append(fetched, obj)
+	}
+	return fetched, nil
+}
+
+// uniqueWalkObjects sorts the objects reached by a step and drops those
+// reached more than once, keeping the first tag.
+func uniqueWalkObjects(reached []*WalkObject) []*WalkObject {
+	sort.SliceStable(reached, func(i, j int) bool {
+		if reached[i].Bucket != reached[j].Bucket {
+			return reached[i].Bucket < reached[j].Bucket
+		}
+		return reached[i].Key < reached[j].Key
+	})
+
+	unique := reached[:0]
+	for i, obj := range reached {
+		if i > 0 && obj.Bucket == reached[i-1].Bucket && obj.Key == reached[i-1].Key {
+			continue
+		}
+		unique = append(unique, obj)
+	}
+	return unique
+}
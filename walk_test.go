@@ -0,0 +1,138 @@
+package riakpbc
+
+import (
+	"encoding/json"
+	"github.com/bmizerany/assert"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+// linkPhases runs a MapReduce job made of link phases on the objects of a
+// memServer, answering with the results of each kept phase.
+func (s *memServer) linkPhases(request []byte) testMessages {
+	var job struct {
+		Inputs [][]string `json:"inputs"`
+		Query  []struct {
+			Link struct {
+				Bucket string `json:"bucket"`
+				Tag    string `json:"tag"`
+				Keep   bool   `json:"keep"`
+			} `json:"link"`
+		} `json:"query"`
+	}
+	json.Unmarshal(request, &job)
+
+	current := job.Inputs
+	var messages testMessages
+	for i, phase := range job.Query {
+		var next [][]string
+		for _, input := range current {
+			obj, ok := s.objects[input[0]+"/"+input[1]]
+			if !ok || obj.deleted {
+				continue
+			}
+			for _, l := range obj.content[0].GetLinks() {
+				if phase.Link.Bucket != "_" && string(l.GetBucket()) != phase.Link.Bucket {
+					continue
+				}
+				if phase.Link.Tag != "_" && string(l.GetTag()) != phase.Link.Tag {
+					continue
+				}
+				next = append(next, []string{string(l.GetBucket()), string(l.GetKey()), string(l.GetTag())})
+			}
+		}
+		if phase.Link.Keep && len(next) > 0 {
+			response, _ := json.Marshal(next)
+			messages = append(messages, &RpbMapRedResp{Phase: proto.Uint32(uint32(i)), Response: response})
+		}
+		current = next
+	}
+
+	return append(messages, &RpbMapRedResp{Done: proto.Bool(true)})
+}
+
+func storeLinked(t *testing.T, riak *Client, bucket, key string, links ...*RpbLink) {
+	_, err := riak.StoreObject(bucket, key, &RpbContent{Value: []byte(key), Links: links})
+	assert.T(t, err == nil)
+}
+
+func walkKeys(step []*WalkObject) []string {
+	keys := []string{}
+	for _, obj := range step {
+		keys = append(keys, obj.Bucket+"/"+obj.Key)
+	}
+	return keys
+}
+
+func TestWalkJob(t *testing.T) {
+	riak := NewClient([]string{"127.0.0.1:8087"})
+
+	job, err := riak.Walk("people", "bob").Link("people", "friend", false).Link("", "", false).Job()
+	assert.T(t, err == nil)
+	assert.Equal(t, `{"inputs":[["people","bob"]],"query":[{"link":{"bucket":"people","tag":"friend","keep":false}},{"link":{"bucket":"_","tag":"_","keep":true}}]}`, job)
+
+	_, err = riak.Walk("people", "bob").Run()
+	assert.T(t, err != nil)
+}
+
+func TestWalk(t *testing.T) {
+	server, addr := newMemServer(t)
+
+	riak := NewClient([]string{addr})
+	assert.T(t, riak.Dial() == nil)
+	defer riak.Close()
+
+	friend := func(key string) *RpbLink {
+		return &RpbLink{Bucket: []byte("people"), Key: []byte(key), Tag: []byte("friend")}
+	}
+	storeLinked(t, riak, "people", "bob", friend("alice"), friend("carol"), friend("ghost"),
+		&RpbLink{Bucket: []byte("posts"), Key: []byte("hello"), Tag: []byte("author")})
+	storeLinked(t, riak, "people", "alice", friend("dave"), friend("bob"))
+	storeLinked(t, riak, "people", "carol", friend("dave"))
+	storeLinked(t, riak, "people", "dave")
+	storeLinked(t, riak, "posts", "hello")
+
+	for _, mapReduce := range []bool{true, false} {
+		server.Lock()
+		server.noMapReduce = !mapReduce
+		server.Unlock()
+
+		steps, err := riak.Walk("people", "bob").
+			Link("people", "friend", true).
+			Link("people", "friend", false).
+			Run()
+		assert.T(t, err == nil)
+		assert.Equal(t, 2, len(steps))
+		assert.Equal(t, []string{"people/alice", "people/carol"}, walkKeys(steps[0]))
+		assert.Equal(t, []string{"people/bob", "people/dave"}, walkKeys(steps[1]))
+		assert.Equal(t, "friend", steps[1][1].Tag)
+		assert.Equal(t, "dave", string(steps[1][1].Object.GetContent()[0].GetValue()))
+
+		steps, err = riak.Walk("people", "bob").Link("", "author", false).Run()
+		assert.T(t, err == nil)
+		assert.Equal(t, []string{"posts/hello"}, walkKeys(steps[0]))
+	}
+
+	server.Lock()
+	server.noMapReduce = true
+	server.Unlock()
+	_, err := riak.Walk("people", "bob").Link("people", "friend", false).RunMapReduce()
+	assert.T(t, err != nil)
+
+	_, err = riak.Walk("people", "nobody").Link("people", "friend", false).Run()
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	// Connection errors aren't hidden behind the fallback
+	server.Lock()
+	server.dropMapReduce = true
+	delete(server.requests, "RpbGetReq")
+	server.Unlock()
+	_, err = riak.Walk("people", "bob").Link("people", "friend", false).Run()
+	assert.T(t, err != nil)
+	_, isRiakError := err.(*RiakError)
+	assert.T(t, !isRiakError)
+	server.Lock()
+	_, fetched := server.requests["RpbGetReq"]
+	server.Unlock()
+	assert.T(t, !fetched)
+}